@@ -1,18 +1,22 @@
 package main
 
 import (
-	"TiffReader/internal/handlers"
 	"context"
 	"errors"
 	"fmt"
+	"github.com/chennequin/fast-tiff-reader/internal/handlers"
+	"github.com/chennequin/fast-tiff-reader/pkg/slide"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/spf13/viper"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -21,11 +25,31 @@ import (
 const (
 	assetsDirectory = "assets"
 	cacheSize       = 100
+	s3Endpoint      = "localhost:4572"
+	s3Bucket        = "slides"
 )
 
 func init() {
 	viper.SetDefault("assets.directory", assetsDirectory)
+	viper.SetDefault("assets.roots", "")
+	viper.SetDefault("assets.symlinks", handlers.SymlinksWithin)
+	viper.SetDefault("assets.extensions", strings.Join(handlers.DefaultAssetsExtensions, ","))
 	viper.SetDefault("reader.cache.size", cacheSize)
+	viper.SetDefault("reader.backend", handlers.BackendPread)
+	viper.SetDefault("deepzoom.overlap", slide.DeepZoomOverlap)
+	viper.SetDefault("region.fill-color", "")
+	viper.SetDefault("http.allowed-hosts", "")
+	viper.SetDefault("http.timeout", slide.URLTimeout)
+	viper.SetDefault("s3.endpoint", s3Endpoint)
+	viper.SetDefault("s3.bucket", s3Bucket)
+	viper.SetDefault("s3.region", "us-east-1")
+	viper.SetDefault("s3.access-key", "test")
+	viper.SetDefault("s3.secret-key", "test")
+	viper.SetDefault("s3.use-ssl", false)
+	viper.SetDefault("s3.timeout", slide.S3Timeout)
+	viper.SetEnvPrefix("tiff")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
 }
 
 func main() {
@@ -50,15 +74,55 @@ func main() {
 	cache := handlers.NewSlideReaderCache(size)
 	defer cache.Close()
 
-	dir := viper.GetString("assets.directory")
-	hf := handlers.NewFileHandlers(dir, cache)
+	// the slides are confined to the assets roots, separated as in PATH, assets.directory by default
+	roots := filepath.SplitList(viper.GetString("assets.roots"))
+	if len(roots) == 0 {
+		roots = []string{viper.GetString("assets.directory")}
+	}
+	assets, err := handlers.NewAssetsResolver(roots, viper.GetString("assets.symlinks"), strings.Split(viper.GetString("assets.extensions"), ","))
+	if err != nil {
+		log.Fatalf("invalid assets configuration: %v", err)
+	}
+	backend := viper.GetString("reader.backend")
+	// the pixels of the regions outside the levels, as RRGGBB or RRGGBBAA, transparent by default
+	fill, err := handlers.ParseFillColor(viper.GetString("region.fill-color"))
+	if err != nil {
+		log.Fatalf("invalid region configuration: %v", err)
+	}
+	hf := handlers.NewFileHandlers(assets, backend, cache, fill)
+
+	s3Client, err := minio.New(viper.GetString("s3.endpoint"), &minio.Options{
+		Creds:        credentials.NewStaticV4(viper.GetString("s3.access-key"), viper.GetString("s3.secret-key"), ""),
+		Secure:       viper.GetBool("s3.use-ssl"),
+		Region:       viper.GetString("s3.region"),
+		BucketLookup: minio.BucketLookupPath, // s3proxy, MinIO and LocalStack expect path-style requests
+	})
+	if err != nil {
+		log.Fatalf("unable to create S3 client: %v", err)
+	}
+	hs3 := handlers.NewS3Handlers(s3Client, viper.GetString("s3.bucket"), cache, viper.GetDuration("s3.timeout"))
+
+	// remote slides are only read from the hosts allowed, comma-separated, none by default
+	hosts := handlers.NewHostAllowList(strings.Split(viper.GetString("http.allowed-hosts"), ","))
+	hhttp := handlers.NewHTTPHandlers(cache, hosts, viper.GetDuration("http.timeout"))
 
-	hs3 := handlers.NewS3Handlers(cache)
+	hdz := handlers.NewDeepZoomHandlers(hf, viper.GetInt("deepzoom.overlap"))
+	hiiif := handlers.NewIIIFHandlers(hf)
 
 	r.GET("/open/file/*path", hf.HandleOpenFile)
 	r.GET("/open/S3/*path", hs3.HandleOpenS3)
+	r.GET("/open/http/*url", hhttp.HandleOpenHTTP)
 	r.GET("files/:tiff/levels/:level/tiles/:xy", hf.HandleGetTile)
-	r.GET("S3/:tiff/levels/:level/tiles/:xy", hs3.HandleOpenS3)
+	r.GET("files/:tiff/region", hf.HandleGetRegion)
+	r.GET("files/:tiff/series", hf.HandleGetSeries)
+	r.GET("files/:tiff/series/:series/c/:c/z/:z/t/:t/levels/:level/tiles/:xy", hf.HandleGetPlaneTile)
+	r.GET("deepzoom/:tiff", hdz.HandleGetDescriptor)
+	r.GET("deepzoom/:tiff/:level/:tile", hdz.HandleGetTile)
+	r.GET("iiif/3/:id", hiiif.HandleRedirectInfo)
+	r.GET("iiif/3/:id/info.json", hiiif.HandleGetInfo)
+	r.GET("iiif/3/:id/:region/:size/:rotation/:file", hiiif.HandleGetImage)
+	r.GET("S3/:tiff/levels/:level/tiles/:xy", hs3.HandleGetTile)
+	r.GET("http/:tiff/levels/:level/tiles/:xy", hhttp.HandleGetTile)
 
 	server := &http.Server{
 		Handler: r,
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"TiffReader/internal/slides"
 	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/slide"
 	"log"
 	"log/slog"
 	"os"
@@ -12,7 +12,7 @@ import (
 func main() {
 	//slog.SetLogLoggerLevel(slog.LevelInfo)
 	slog.SetLogLoggerLevel(slog.LevelDebug)
-	//slog.SetLogLoggerLevel(tiffio.LogLevelTrace)
+	//slog.SetLogLoggerLevel(tiff.LogLevelTrace)
 
 	//name := "assets/generic/CMU-1.tiff"
 	//name := "assets/philips/Philips-1.tiff"
@@ -65,16 +65,16 @@ func main() {
 	println("OK")
 }
 
-func openSlide(name string) (*slides.SlideReader, error) {
+func openSlide(name string) (*slide.SlideReader, error) {
 	start := time.Now()
 	defer func() { fmt.Printf("Opening execution time: %s\n", time.Since(start)) }()
 
-	reader := slides.NewSlideReader()
+	reader := slide.NewSlideReader()
 	err := reader.OpenFile(name)
 	return reader, err
 }
 
-func readTile(reader *slides.SlideReader, levelIdx, tileIdx int) ([]byte, error) {
+func readTile(reader *slide.SlideReader, levelIdx, tileIdx int) ([]byte, error) {
 	start := time.Now()
 	defer func() { fmt.Printf("Reading execution time: %s\n", time.Since(start)) }()
 
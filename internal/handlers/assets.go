@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policies of the symbolic links met while resolving the path of a slide, see AssetsResolver.
+const (
+	SymlinksDeny   = "deny"   // a symbolic link below the root rejects the path
+	SymlinksWithin = "within" // symbolic links are followed while their target stays inside one of the roots
+	SymlinksFollow = "follow" // symbolic links are followed anywhere
+)
+
+// DefaultAssetsExtensions are the extensions of the slides served by default, OME-TIFF (.ome.tif) included.
+var DefaultAssetsExtensions = []string{".tif", ".tiff", ".svs", ".ndpi", ".scn", ".bif", ".btf", ".tf2", ".tf8"}
+
+// ErrForbiddenPath is returned for the paths escaping the assets roots or rejected by their policies.
+var ErrForbiddenPath = errors.New("forbidden path")
+
+// AssetsResolver confines the paths of the slides requested by the clients to the assets roots.
+// A path is relative to a root, the roots being searched in order: absolute paths, paths escaping the root,
+// files of another extension and symbolic links not allowed by the policy are rejected.
+type AssetsResolver struct {
+	roots      []string // absolute, their own symbolic links being resolved on each request
+	symlinks   string
+	extensions []string // lower case, with the leading dot, any extension being allowed when empty
+}
+
+func NewAssetsResolver(roots []string, symlinks string, extensions []string) (*AssetsResolver, error) {
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no assets root")
+	}
+	switch symlinks {
+	case SymlinksDeny, SymlinksWithin, SymlinksFollow:
+	default:
+		return nil, fmt.Errorf("invalid symlinks policy %q, expected %s, %s or %s", symlinks, SymlinksDeny, SymlinksWithin, SymlinksFollow)
+	}
+
+	resolver := &AssetsResolver{symlinks: symlinks}
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid assets root %s: %w", root, err)
+		}
+		resolver.roots = append(resolver.roots, abs)
+	}
+	for _, extension := range extensions {
+		extension = strings.ToLower(strings.TrimSpace(extension))
+		if extension == "" {
+			continue
+		}
+		if !strings.HasPrefix(extension, ".") {
+			extension = "." + extension
+		}
+		resolver.extensions = append(resolver.extensions, extension)
+	}
+	return resolver, nil
+}
+
+// Resolve returns the path of the file named by a client, relative to the first root holding it.
+// The error wraps ErrForbiddenPath when the name is rejected, fs.ErrNotExist when no root holds the file.
+func (a *AssetsResolver) Resolve(name string) (string, error) {
+	if !filepath.IsLocal(name) || strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("%w: %q is not a local path", ErrForbiddenPath, name)
+	}
+	if !a.allowedExtension(name) {
+		return "", fmt.Errorf("%w: extension of %q not allowed", ErrForbiddenPath, name)
+	}
+
+	roots := a.realRoots()
+	for _, root := range roots {
+		path := filepath.Join(root, name)
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+
+		resolved := path
+		switch a.symlinks {
+		case SymlinksDeny:
+			if err := checkNoSymlink(root, name); err != nil {
+				return "", err
+			}
+		default:
+			var err error
+			if resolved, err = filepath.EvalSymlinks(path); err != nil {
+				return "", fmt.Errorf("unable to resolve %q: %w", name, err)
+			}
+			if a.symlinks == SymlinksWithin && !withinRoots(resolved, roots) {
+				return "", fmt.Errorf("%w: %q links outside of the assets roots", ErrForbiddenPath, name)
+			}
+			if !a.allowedExtension(resolved) {
+				return "", fmt.Errorf("%w: extension of the target of %q not allowed", ErrForbiddenPath, name)
+			}
+		}
+
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return "", fmt.Errorf("unable to stat %q: %w", name, err)
+		}
+		if !info.Mode().IsRegular() {
+			return "", fmt.Errorf("%w: %q is not a regular file", ErrForbiddenPath, name)
+		}
+		return resolved, nil
+	}
+	return "", fmt.Errorf("%q: %w", name, fs.ErrNotExist)
+}
+
+// realRoots returns the roots whose symbolic links are resolved, the missing roots being skipped.
+func (a *AssetsResolver) realRoots() []string {
+	roots := make([]string, 0, len(a.roots))
+	for _, root := range a.roots {
+		resolved, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			slog.Debug("Skipping assets root", "root", root, "error", err)
+			continue
+		}
+		roots = append(roots, resolved)
+	}
+	return roots
+}
+
+func (a *AssetsResolver) allowedExtension(name string) bool {
+	if len(a.extensions) == 0 {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, extension := range a.extensions {
+		if strings.HasSuffix(lower, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNoSymlink rejects the relative path when one of its components below the root is a symbolic link.
+func checkNoSymlink(root, name string) error {
+	path := root
+	for _, component := range strings.Split(filepath.Clean(name), string(filepath.Separator)) {
+		path = filepath.Join(path, component)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return fmt.Errorf("unable to stat %q: %w", name, err)
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %q holds a symbolic link", ErrForbiddenPath, name)
+		}
+	}
+	return nil
+}
+
+func withinRoots(path string, roots []string) bool {
+	for _, root := range roots {
+		if rel, err := filepath.Rel(root, path); err == nil && filepath.IsLocal(rel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// assetsTree creates two assets roots, a and b, and a directory outside of them, returning their parent.
+func assetsTree(t *testing.T) string {
+	t.Helper()
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"a/sub", "a/dir.tiff", "b", "outside"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"a/slide.svs", "a/upper.SVS", "a/notes.txt", "a/sub/deep.tiff", "b/other.tiff", "b/slide.svs", "outside/secret.svs"} {
+		if err := os.WriteFile(filepath.Join(base, file), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"a/link-in.svs":   "slide.svs",
+		"a/link-out.svs":  "../outside/secret.svs",
+		"a/link-dir":      "sub",
+		"a/link-b.tiff":   "../b/other.tiff",
+		"a/link-txt.svs":  "notes.txt",
+		"a/dangling.svs":  "missing.svs",
+		"a/outside-dir":   "../outside",
+		"b/link-root.svs": filepath.Join(base, "a", "slide.svs"),
+	} {
+		if err := os.Symlink(target, filepath.Join(base, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return base
+}
+
+func TestAssetsResolverResolve(t *testing.T) {
+	base := assetsTree(t)
+
+	// result is the file resolved relative to base, or the error expected
+	type result struct {
+		path string
+		err  error
+	}
+	found := func(path string) result { return result{path: path} }
+	forbidden := result{err: ErrForbiddenPath}
+	notExist := result{err: fs.ErrNotExist}
+
+	tests := []struct {
+		name                 string
+		path                 string
+		deny, within, follow result
+	}{
+		{"file", "slide.svs", found("a/slide.svs"), found("a/slide.svs"), found("a/slide.svs")},
+		{"upper case extension", "upper.SVS", found("a/upper.SVS"), found("a/upper.SVS"), found("a/upper.SVS")},
+		{"subdirectory", "sub/deep.tiff", found("a/sub/deep.tiff"), found("a/sub/deep.tiff"), found("a/sub/deep.tiff")},
+		{"second root", "other.tiff", found("b/other.tiff"), found("b/other.tiff"), found("b/other.tiff")},
+		{"missing", "missing.svs", notExist, notExist, notExist},
+		{"parent traversal", "../outside/secret.svs", forbidden, forbidden, forbidden},
+		{"traversal after a subdirectory", "sub/../../outside/secret.svs", forbidden, forbidden, forbidden},
+		{"absolute", filepath.Join(base, "a", "slide.svs"), forbidden, forbidden, forbidden},
+		{"NUL byte", "slide.svs\x00.svs", forbidden, forbidden, forbidden},
+		{"extension not allowed", "notes.txt", forbidden, forbidden, forbidden},
+		{"directory", "dir.tiff", forbidden, forbidden, forbidden},
+		{"link within the root", "link-in.svs", forbidden, found("a/slide.svs"), found("a/slide.svs")},
+		{"link to a directory", "link-dir/deep.tiff", forbidden, found("a/sub/deep.tiff"), found("a/sub/deep.tiff")},
+		{"link to another root", "link-b.tiff", forbidden, found("b/other.tiff"), found("b/other.tiff")},
+		{"absolute link to another root", "link-root.svs", forbidden, found("a/slide.svs"), found("a/slide.svs")},
+		{"link escaping the root", "link-out.svs", forbidden, forbidden, found("outside/secret.svs")},
+		{"directory link escaping the root", "outside-dir/secret.svs", forbidden, forbidden, found("outside/secret.svs")},
+		{"link to an extension not allowed", "link-txt.svs", forbidden, forbidden, forbidden},
+		{"dangling link", "dangling.svs", forbidden, notExist, notExist},
+	}
+	for _, policy := range []string{SymlinksDeny, SymlinksWithin, SymlinksFollow} {
+		resolver, err := NewAssetsResolver([]string{filepath.Join(base, "a"), filepath.Join(base, "b")}, policy, DefaultAssetsExtensions)
+		if err != nil {
+			t.Fatalf("NewAssetsResolver: %v", err)
+		}
+		for _, tt := range tests {
+			want := map[string]result{SymlinksDeny: tt.deny, SymlinksWithin: tt.within, SymlinksFollow: tt.follow}[policy]
+			t.Run(policy+"/"+tt.name, func(t *testing.T) {
+				got, err := resolver.Resolve(tt.path)
+				if want.err != nil {
+					if !errors.Is(err, want.err) {
+						t.Fatalf("Resolve(%q) = %q, %v, want %v", tt.path, got, err, want.err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Resolve(%q): %v", tt.path, err)
+				}
+				if wantPath := filepath.Join(base, want.path); got != wantPath {
+					t.Errorf("Resolve(%q) = %q, want %q", tt.path, got, wantPath)
+				}
+			})
+		}
+	}
+}
+
+func TestNewAssetsResolver(t *testing.T) {
+	base := assetsTree(t)
+	tests := []struct {
+		name       string
+		roots      []string
+		symlinks   string
+		extensions []string
+		resolve    string
+		wantErr    bool
+	}{
+		{"any extension", []string{filepath.Join(base, "a")}, SymlinksDeny, nil, "notes.txt", false},
+		{"extensions normalised", []string{filepath.Join(base, "a")}, SymlinksDeny, []string{" TXT ", ""}, "notes.txt", false},
+		{"missing root skipped", []string{filepath.Join(base, "missing"), filepath.Join(base, "b")}, SymlinksDeny, nil, "other.tiff", false},
+		{"no root", nil, SymlinksDeny, nil, "", true},
+		{"unknown policy", []string{filepath.Join(base, "a")}, "always", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewAssetsResolver(tt.roots, tt.symlinks, tt.extensions)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NewAssetsResolver: no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewAssetsResolver: %v", err)
+			}
+			if _, err := resolver.Resolve(tt.resolve); err != nil {
+				t.Errorf("Resolve(%q): %v", tt.resolve, err)
+			}
+		})
+	}
+}
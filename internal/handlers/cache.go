@@ -1,14 +1,14 @@
 package handlers
 
 import (
-	"TiffReader/internal/slides"
+	"github.com/chennequin/fast-tiff-reader/pkg/slide"
 	"github.com/scalalang2/golang-fifo/sieve"
 	"github.com/scalalang2/golang-fifo/types"
 )
 
 type SlideReaderCacheEntry struct {
-	reader   *slides.SlideReader
-	metadata *slides.PyramidMetadata
+	reader   *slide.SlideReader
+	metadata *slide.PyramidMetadata
 }
 
 type SlideReaderCache struct {
@@ -23,14 +23,17 @@ func NewSlideReaderCache(cacheSize int) *SlideReaderCache {
 	return &SlideReaderCache{cache: cache}
 }
 
-func (c *SlideReaderCache) Get(tiffFile string) (*slides.SlideReader, *slides.PyramidMetadata, bool) {
-	if entry, ok := c.cache.Get(tiffFile); ok {
+// Get returns the cached reader of tiffFile retained for the caller, who releases it once its tiles are written:
+// an eviction meanwhile only closes the reader with the last release. A reader closed by an eviction is a miss.
+func (c *SlideReaderCache) Get(tiffFile string) (*slide.SlideReader, *slide.PyramidMetadata, bool) {
+	if entry, ok := c.cache.Get(tiffFile); ok && entry.reader.Retain() {
 		return entry.reader, entry.metadata, true
 	}
 	return nil, nil, false
 }
 
-func (c *SlideReaderCache) Set(tiffFile string, reader *slides.SlideReader, metadata *slides.PyramidMetadata) {
+// Set caches the reader of tiffFile, which is closed when evicted.
+func (c *SlideReaderCache) Set(tiffFile string, reader *slide.SlideReader, metadata *slide.PyramidMetadata) {
 	entry := SlideReaderCacheEntry{
 		reader:   reader,
 		metadata: metadata,
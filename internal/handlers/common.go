@@ -1,13 +1,54 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/chennequin/fast-tiff-reader/pkg/slide"
 	"github.com/gin-gonic/gin"
 	"github.com/jxskiss/base62"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"log/slog"
+	"net/http"
 	"strconv"
 	"strings"
 )
 
+// maxRegionPixels limits the size of the regions read at once, as a region is held decoded in memory.
+const maxRegionPixels = 4096 * 4096
+
+// ErrBadRequest is returned for the invalid parameters of the requests.
+var ErrBadRequest = errors.New("bad request")
+
+// problemStatuses are the statuses of the typed errors of the slides and of the assets, served as problem details (RFC 9457).
+var problemStatuses = []struct {
+	err    error
+	status int
+}{
+	{ErrBadRequest, http.StatusBadRequest},
+	{ErrForbiddenPath, http.StatusForbidden},
+	{ErrForbiddenURL, http.StatusForbidden},
+	{fs.ErrNotExist, http.StatusNotFound},
+	{slide.ErrLevelOutOfRange, http.StatusBadRequest},
+	{slide.ErrPlaneOutOfRange, http.StatusNotFound},
+	{slide.ErrRegionTooLarge, http.StatusBadRequest},
+	{slide.ErrTileOutOfRange, http.StatusNotFound},
+	{slide.ErrUnsupportedCompression, http.StatusUnsupportedMediaType},
+}
+
+// regionParams is a region of a level in the coordinates of the level 0, see slide.SlideReader.ReadRegion.
+type regionParams struct {
+	level  int
+	x, y   int
+	width  int
+	height int
+	format string // jpeg or png
+}
+
 func handleTileParams(c *gin.Context) (string, int, int, int, error) {
 	encoded := c.Param("tiff")
 	level := c.Param("level")
@@ -46,3 +87,113 @@ func handleTileParams(c *gin.Context) (string, int, int, int, error) {
 
 	return tiffFile, levelIdx, x, y, nil
 }
+
+// handlePlaneTileParams parses the address of a tile of an OME-TIFF file, see slide.TileAddress.
+func handlePlaneTileParams(c *gin.Context) (string, slide.TileAddress, error) {
+	tiffFile, levelIdx, x, y, err := handleTileParams(c)
+	if err != nil {
+		return "", slide.TileAddress{}, err
+	}
+
+	address := slide.TileAddress{Level: levelIdx, X: x, Y: y}
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{
+		{"series", &address.Series},
+		{"c", &address.Channel},
+		{"z", &address.Z},
+		{"t", &address.T},
+	} {
+		v, err := strconv.Atoi(c.Param(p.name))
+		if err != nil {
+			return "", slide.TileAddress{}, fmt.Errorf("invalid %s", p.name)
+		}
+		*p.value = v
+	}
+	return tiffFile, address, nil
+}
+
+func handleRegionParams(c *gin.Context) (string, regionParams, error) {
+	decoded, err := base62.DecodeString(c.Param("tiff"))
+	if err != nil {
+		return "", regionParams{}, fmt.Errorf("failed to base62 decode path")
+	}
+	tiffFile := string(decoded)
+
+	var params regionParams
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{
+		{"level", &params.level},
+		{"x", &params.x},
+		{"y", &params.y},
+		{"w", &params.width},
+		{"h", &params.height},
+	} {
+		v, err := strconv.Atoi(c.Query(p.name))
+		if err != nil {
+			return "", regionParams{}, fmt.Errorf("invalid or missing %s", p.name)
+		}
+		*p.value = v
+	}
+	// the width is divided rather than multiplied, so that huge sizes cannot overflow
+	if params.width <= 0 || params.height <= 0 || params.width > maxRegionPixels/params.height {
+		return "", regionParams{}, fmt.Errorf("invalid region size %dx%d, at most %d pixels", params.width, params.height, maxRegionPixels)
+	}
+
+	params.format = strings.ToLower(c.DefaultQuery("format", "jpeg"))
+	switch params.format {
+	case "jpeg", "jpg":
+		params.format = "jpeg"
+	case "png":
+	default:
+		return "", regionParams{}, fmt.Errorf("invalid format %s, expected jpeg or png", params.format)
+	}
+	return tiffFile, params, nil
+}
+
+// encodeImage encodes an image as jpeg, png or webp (lossless), returning its content type.
+func encodeImage(img image.Image, format string) ([]byte, string, error) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	switch format {
+	case "webp":
+		if err := nativewebp.Encode(buf, img, nil); err != nil {
+			return nil, "", fmt.Errorf("unable to encode WebP: %w", err)
+		}
+		return buf.Bytes(), "image/webp", nil
+	case "png":
+		if err := png.Encode(buf, img); err != nil {
+			return nil, "", fmt.Errorf("unable to encode PNG: %w", err)
+		}
+		return buf.Bytes(), "image/png", nil
+	default:
+		if err := jpeg.Encode(buf, img, nil); err != nil {
+			return nil, "", fmt.Errorf("unable to encode JPEG: %w", err)
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+}
+
+// respondError responds to a failed read: the typed errors of the slides as problem details with their status,
+// the other errors as an internal error with message. The rejected paths and URLs are audited.
+func respondError(c *gin.Context, err error, message string) {
+	if errors.Is(err, ErrForbiddenPath) || errors.Is(err, ErrForbiddenURL) {
+		slog.Warn("Audit: rejected request", "client", c.ClientIP(), "method", c.Request.Method, "uri", c.Request.URL.RequestURI(), "error", err)
+	}
+	for _, problem := range problemStatuses {
+		if errors.Is(err, problem.err) {
+			c.Header("Content-Type", "application/problem+json")
+			c.JSON(problem.status, gin.H{
+				"type":     "about:blank",
+				"title":    http.StatusText(problem.status),
+				"status":   problem.status,
+				"detail":   err.Error(),
+				"instance": c.Request.URL.Path,
+			})
+			return
+		}
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
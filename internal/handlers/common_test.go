@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/chennequin/fast-tiff-reader/pkg/slide"
+	"github.com/gin-gonic/gin"
+	"github.com/jxskiss/base62"
+)
+
+func TestHandleRegionParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	encoded := base62.EncodeToString([]byte("test.tiff"))
+
+	tests := []struct {
+		name    string
+		query   string
+		want    regionParams
+		wantErr bool
+	}{
+		{"jpeg by default", "level=1&x=2048&y=1024&w=512&h=512", regionParams{1, 2048, 1024, 512, 512, "jpeg"}, false},
+		{"png", "level=0&x=0&y=0&w=10&h=20&format=PNG", regionParams{0, 0, 0, 10, 20, "png"}, false},
+		{"jpg", "level=0&x=0&y=0&w=10&h=20&format=jpg", regionParams{0, 0, 0, 10, 20, "jpeg"}, false},
+		{"largest region", "level=0&x=0&y=0&w=4096&h=4096", regionParams{0, 0, 0, 4096, 4096, "jpeg"}, false},
+		{"one pixel too many", "level=0&x=0&y=0&w=4097&h=4096", regionParams{}, true},
+		{"zero width", "level=0&x=0&y=0&w=0&h=10", regionParams{}, true},
+		{"negative height", "level=0&x=0&y=0&w=10&h=-10", regionParams{}, true},
+		{"overflowing product", "level=0&x=0&y=0&w=" + strconv.Itoa(1<<32) + "&h=" + strconv.Itoa(1<<32), regionParams{}, true},
+		{"missing height", "level=0&x=0&y=0&w=10", regionParams{}, true},
+		{"unknown format", "level=0&x=0&y=0&w=10&h=10&format=gif", regionParams{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/files/"+encoded+"/region?"+tt.query, nil)
+			c.Params = gin.Params{{Key: "tiff", Value: encoded}}
+
+			tiffFile, got, err := handleRegionParams(c)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("handleRegionParams(%s) = %+v, want error", tt.query, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("handleRegionParams(%s): %v", tt.query, err)
+			}
+			if tiffFile != "test.tiff" || got != tt.want {
+				t.Errorf("handleRegionParams(%s) = %s, %+v, want test.tiff, %+v", tt.query, tiffFile, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRespondError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantProblem bool
+	}{
+		{"invalid parameter", fmt.Errorf("%w: invalid level", ErrBadRequest), http.StatusBadRequest, true},
+		{"forbidden path", fmt.Errorf("%w: ../slide.svs", ErrForbiddenPath), http.StatusForbidden, true},
+		{"missing object", fmt.Errorf("unable to stat object: %w", fs.ErrNotExist), http.StatusNotFound, true},
+		{"level out of range", fmt.Errorf("%w: 12", slide.ErrLevelOutOfRange), http.StatusBadRequest, true},
+		{"unsupported samples", fmt.Errorf("%w: BitsPerSample 4", slide.ErrUnsupportedCompression), http.StatusUnsupportedMediaType, true},
+		{"other error", errors.New("connection reset"), http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest("GET", "/S3/abc/levels/0/tiles/0_0.jpeg", nil)
+
+			respondError(c, tt.err, "Failed to read tile")
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			isProblem := strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/problem+json")
+			if isProblem != tt.wantProblem {
+				t.Errorf("Content-Type %q, problem details %v expected", recorder.Header().Get("Content-Type"), tt.wantProblem)
+			}
+		})
+	}
+}
@@ -1,19 +1,125 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/slide"
+	"github.com/gin-gonic/gin"
+	"github.com/jxskiss/base62"
+	"github.com/minio/minio-go/v7"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
 
 type S3Handlers struct {
-	cache *SlideReaderCache
+	client  *minio.Client
+	bucket  string
+	cache   *SlideReaderCache
+	timeout time.Duration
 }
 
-func NewS3Handlers(cache *SlideReaderCache) *S3Handlers {
-	return &S3Handlers{cache}
+// NewS3Handlers creates the handlers of the objects of bucket, each request being bounded by timeout.
+func NewS3Handlers(client *minio.Client, bucket string, cache *SlideReaderCache, timeout time.Duration) *S3Handlers {
+	return &S3Handlers{
+		client:  client,
+		bucket:  bucket,
+		cache:   cache,
+		timeout: timeout,
+	}
 }
 
 func (t *S3Handlers) HandleGetTile(c *gin.Context) {
+	tiffFile, levelIdx, x, y, err := handleTileParams(c)
+	if err != nil {
+		slog.Error("Error opening object", "key", tiffFile, "error", err)
+		respondError(c, fmt.Errorf("%w: %w", ErrBadRequest, err), "Invalid request")
+		return
+	}
 
+	reader, metadata, ok := t.cache.Get(t.cacheKey(tiffFile))
+	if !ok {
+		reader, metadata, err = t.openS3Reader(tiffFile)
+		if err != nil {
+			slog.Error("Error opening object", "key", tiffFile, "error", err)
+			respondError(c, err, "Failed to open object")
+			return
+		}
+	}
+	defer reader.Release()
+
+	tileIdx, err := metadata.TileIndex(levelIdx, x, y)
+	if err != nil {
+		respondError(c, err, "Invalid tile")
+		return
+	}
+	imageData, err := reader.GetTile(levelIdx, tileIdx)
+	if err != nil {
+		slog.Error("Error while serving tile", "levelIdx", levelIdx, "x", x, "y", y, "key", tiffFile, "error", err)
+		respondError(c, err, "Failed to read tile")
+		return
+	}
+
+	c.Data(http.StatusOK, http.DetectContentType(imageData), imageData)
 }
 
 func (t *S3Handlers) HandleOpenS3(c *gin.Context) {
+	tiffFile := strings.TrimPrefix(c.Param("path"), "/")
+
+	// Encode the object key in a URL-friendly format
+	encoded := base62.EncodeToString([]byte(tiffFile))
+
+	if reader, metadata, ok := t.cache.Get(t.cacheKey(tiffFile)); ok {
+		defer reader.Release()
+		c.JSON(200, gin.H{
+			"encoded":    encoded,
+			"decoded":    tiffFile,
+			"vendor":     reader.GetVendor(),
+			"metadata":   metadata,
+			"properties": reader.Properties(),
+		})
+		return
+	}
+
+	reader, metadata, err := t.openS3Reader(tiffFile)
+	if err != nil {
+		slog.Error("Error opening object", "key", tiffFile, "error", err)
+		respondError(c, err, "Failed to open object")
+		return
+	}
+	defer reader.Release()
+
+	c.JSON(200, gin.H{
+		"encoded":    encoded,
+		"decoded":    tiffFile,
+		"vendor":     reader.GetVendor(),
+		"metadata":   metadata,
+		"properties": reader.Properties(),
+	})
+}
+
+// cacheKey distinguishes S3 objects from local files sharing the same SlideReaderCache.
+func (t *S3Handlers) cacheKey(key string) string {
+	return fmt.Sprintf("s3://%s/%s", t.bucket, key)
+}
+
+func (t *S3Handlers) openS3Reader(key string) (*slide.SlideReader, *slide.PyramidMetadata, error) {
+	// Open the object and retrieve its metadata
+	reader := slide.NewSlideReader()
+	err := reader.OpenS3WithTimeout(t.client, t.bucket, key, t.timeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open S3 object: %w", err)
+	}
+
+	metadata, err := reader.GetMetadata()
+	if err != nil {
+		reader.Close()
+		return nil, nil, fmt.Errorf("failed to read metadata from: %w", err)
+	}
+
+	// put in memory cache, the reader being retained for the caller
+	reader.Retain()
+	t.cache.Set(t.cacheKey(key), reader, &metadata)
 
+	return reader, &metadata, nil
 }
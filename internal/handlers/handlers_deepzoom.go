@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/slide"
+	"github.com/gin-gonic/gin"
+	"github.com/jxskiss/base62"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	deepZoomDescriptorSuffix = ".dzi"
+	deepZoomFilesSuffix      = "_files"
+	deepZoomFormat           = "jpeg"
+)
+
+// DeepZoomHandlers serve the local slides as Deep Zoom pyramids, for OpenSeadragon:
+// /deepzoom/<encoded>.dzi and /deepzoom/<encoded>_files/<level>/<col>_<row>.jpeg.
+type DeepZoomHandlers struct {
+	files   *FileHandlers
+	overlap int
+}
+
+func NewDeepZoomHandlers(files *FileHandlers, overlap int) *DeepZoomHandlers {
+	return &DeepZoomHandlers{
+		files:   files,
+		overlap: overlap,
+	}
+}
+
+// HandleGetDescriptor serves the DZI descriptor of a slide.
+func (t *DeepZoomHandlers) HandleGetDescriptor(c *gin.Context) {
+	encoded := strings.TrimSuffix(c.Param("tiff"), deepZoomDescriptorSuffix)
+	if encoded == c.Param("tiff") {
+		respondError(c, fmt.Errorf("%w: invalid descriptor, expected .dzi", ErrBadRequest), "Invalid request")
+		return
+	}
+	tiffFile, deepZoom, release, err := t.deepZoom(encoded)
+	if err != nil {
+		slog.Error("Error opening Deep Zoom pyramid", "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to open file")
+		return
+	}
+	defer release()
+
+	descriptor, err := deepZoom.Descriptor(deepZoomFormat)
+	if err != nil {
+		slog.Error("Error while serving Deep Zoom descriptor", "file", tiffFile, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode descriptor"})
+		return
+	}
+	c.Data(http.StatusOK, "application/xml", descriptor)
+}
+
+// HandleGetTile serves a tile of a Deep Zoom level.
+func (t *DeepZoomHandlers) HandleGetTile(c *gin.Context) {
+	encoded := strings.TrimSuffix(c.Param("tiff"), deepZoomFilesSuffix)
+	level, col, row, err := deepZoomTileParams(encoded, c.Param("tiff"), c.Param("level"), c.Param("tile"))
+	if err != nil {
+		respondError(c, fmt.Errorf("%w: %w", ErrBadRequest, err), "Invalid request")
+		return
+	}
+	tiffFile, deepZoom, release, err := t.deepZoom(encoded)
+	if err != nil {
+		slog.Error("Error opening Deep Zoom pyramid", "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to open file")
+		return
+	}
+	defer release()
+
+	img, err := deepZoom.Tile(level, col, row)
+	if err != nil {
+		slog.Error("Error while serving Deep Zoom tile", "level", level, "col", col, "row", row, "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to read tile")
+		return
+	}
+	imageData, contentType, err := encodeImage(img, deepZoomFormat)
+	if err != nil {
+		slog.Error("Error while encoding Deep Zoom tile", "file", tiffFile, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode tile"})
+		return
+	}
+	c.Data(http.StatusOK, contentType, imageData)
+}
+
+// deepZoom opens the Deep Zoom pyramid of the slide whose path is base62 encoded,
+// its reader being retained until release is called.
+func (t *DeepZoomHandlers) deepZoom(encoded string) (tiffFile string, deepZoom *slide.DeepZoom, release func(), err error) {
+	decoded, err := base62.DecodeString(encoded)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%w: failed to base62 decode path", ErrBadRequest)
+	}
+	tiffFile = string(decoded)
+
+	reader, _, err := t.files.getReader(tiffFile)
+	if err != nil {
+		return tiffFile, nil, nil, err
+	}
+	deepZoom, err = slide.NewDeepZoom(reader, slide.DeepZoomTileSize, t.overlap)
+	if err != nil {
+		reader.Release()
+		return tiffFile, nil, nil, err
+	}
+	return tiffFile, deepZoom, reader.Release, nil
+}
+
+func deepZoomTileParams(encoded, files, level, tile string) (int, int, int, error) {
+	if encoded == files {
+		return 0, 0, 0, fmt.Errorf("invalid tile path, expected _files")
+	}
+	levelIdx, err := strconv.Atoi(level)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid level")
+	}
+	colRow := strings.TrimSuffix(tile, "."+deepZoomFormat)
+	if colRow == tile {
+		return 0, 0, 0, fmt.Errorf("invalid tile format, expected .jpeg")
+	}
+	coordinates := strings.Split(colRow, "_")
+	if len(coordinates) != 2 {
+		return 0, 0, 0, fmt.Errorf("invalid tile coordinates")
+	}
+	col, err := strconv.Atoi(coordinates[0])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("conversion error for column")
+	}
+	row, err := strconv.Atoi(coordinates[1])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("conversion error for row")
+	}
+	return levelIdx, col, row, nil
+}
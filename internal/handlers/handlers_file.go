@@ -1,55 +1,183 @@
 package handlers
 
 import (
-	"TiffReader/internal/slides"
+	"encoding/hex"
 	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/slide"
 	"github.com/gin-gonic/gin"
 	"github.com/jxskiss/base62"
+	"image/color"
 	"log/slog"
 	"net/http"
 	"strings"
 )
 
+// Backends reading the local files.
+const (
+	BackendPread = "pread" // positional reads, see tiff.FileBinaryReader
+	BackendMmap  = "mmap"  // memory mapping, see tiff.MmapBinaryReader
+)
+
 type FileHandlers struct {
-	assetsDirectory string
-	cache           *SlideReaderCache
+	assets  *AssetsResolver
+	backend string
+	cache   *SlideReaderCache
+	fill    color.Color // colour of the regions outside the levels, transparent if nil
 }
 
-func NewFileHandlers(directory string, cache *SlideReaderCache) *FileHandlers {
+func NewFileHandlers(assets *AssetsResolver, backend string, cache *SlideReaderCache, fill color.Color) *FileHandlers {
 	return &FileHandlers{
-		assetsDirectory: directory,
-		cache:           cache,
+		assets:  assets,
+		backend: backend,
+		cache:   cache,
+		fill:    fill,
 	}
 }
 
+// ParseFillColor parses a colour written as RRGGBB or RRGGBBAA in hexadecimal, optionally prefixed by #,
+// the empty string being the transparent default.
+func ParseFillColor(value string) (color.Color, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if value == "" {
+		return nil, nil
+	}
+	rgba, err := hex.DecodeString(value)
+	if err != nil || (len(rgba) != 3 && len(rgba) != 4) {
+		return nil, fmt.Errorf("invalid fill color %q, expected RRGGBB or RRGGBBAA", value)
+	}
+	if len(rgba) == 3 {
+		rgba = append(rgba, 0xff)
+	}
+	// color.RGBA is alpha-premultiplied
+	return color.NRGBA{R: rgba[0], G: rgba[1], B: rgba[2], A: rgba[3]}, nil
+}
+
 func (t *FileHandlers) HandleGetTile(c *gin.Context) {
 	tiffFile, levelIdx, x, y, err := handleTileParams(c)
 	if err != nil {
 		slog.Error("Error opening file", "file", tiffFile, "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, fmt.Errorf("%w: %w", ErrBadRequest, err), "Invalid request")
+		return
+	}
+
+	reader, metadata, err := t.getReader(tiffFile)
+	if err != nil {
+		slog.Error("Error opening file", "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to open file")
+		return
+	}
+	defer reader.Release()
+
+	tileIdx, err := metadata.TileIndex(levelIdx, x, y)
+	if err != nil {
+		respondError(c, err, "Invalid tile")
+		return
+	}
+	imageData, err := reader.GetTile(levelIdx, tileIdx)
+	if err != nil {
+		slog.Error("Error while serving tile", "levelIdx", levelIdx, "x", x, "y", y, "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to read tile")
+		return
+	}
+
+	c.Data(http.StatusOK, http.DetectContentType(imageData), imageData)
+}
+
+// seriesResponse is a series of an OME-TIFF file with the pyramid of its planes, all of them sharing the same levels.
+type seriesResponse struct {
+	slide.Series
+	Metadata slide.PyramidMetadata `json:"metadata"`
+}
+
+// HandleGetSeries lists the series of an OME-TIFF file, empty for the other slides.
+func (t *FileHandlers) HandleGetSeries(c *gin.Context) {
+	decoded, err := base62.DecodeString(c.Param("tiff"))
+	if err != nil {
+		respondError(c, fmt.Errorf("%w: failed to base62 decode path", ErrBadRequest), "Invalid request")
+		return
+	}
+	tiffFile := string(decoded)
+
+	reader, _, err := t.getReader(tiffFile)
+	if err != nil {
+		slog.Error("Error opening file", "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to open file")
 		return
 	}
+	defer reader.Release()
 
-	reader, metadata, ok := t.cache.Get(tiffFile)
-	if !ok {
-		reader, metadata, err = t.openFileReader(tiffFile)
+	series := make([]seriesResponse, 0, len(reader.GetSeries()))
+	for seriesIdx, s := range reader.GetSeries() {
+		metadata, err := reader.GetPlaneMetadata(seriesIdx, slide.Plane{})
 		if err != nil {
-			slog.Error("Error opening file", "file", tiffFile, "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+			slog.Error("Error while reading series", "series", seriesIdx, "file", tiffFile, "error", err)
+			respondError(c, err, "Failed to read series")
 			return
 		}
+		series = append(series, seriesResponse{Series: s, Metadata: metadata})
 	}
+	c.JSON(http.StatusOK, gin.H{"series": series})
+}
 
-	//TODO protect index
-	tileIdx := metadata.Levels[levelIdx].TileIndex(x, y)
-	imageData, err := reader.GetTile(levelIdx, tileIdx)
+// HandleGetPlaneTile serves a tile of a plane of an OME-TIFF file, addressed by series, channel, focal plane,
+// timepoint, level and position.
+func (t *FileHandlers) HandleGetPlaneTile(c *gin.Context) {
+	tiffFile, address, err := handlePlaneTileParams(c)
 	if err != nil {
-		slog.Error("Error while serving tile", "levelIdx", levelIdx, "x", x, "y", y, "file", tiffFile, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read tile"})
+		slog.Error("Invalid plane tile request", "file", tiffFile, "error", err)
+		respondError(c, fmt.Errorf("%w: %w", ErrBadRequest, err), "Invalid request")
+		return
+	}
+
+	reader, _, err := t.getReader(tiffFile)
+	if err != nil {
+		slog.Error("Error opening file", "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to open file")
+		return
+	}
+	defer reader.Release()
+
+	imageData, err := reader.GetPlaneTile(address)
+	if err != nil {
+		slog.Error("Error while serving plane tile", "address", address, "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to read tile")
+		return
+	}
+
+	c.Data(http.StatusOK, http.DetectContentType(imageData), imageData)
+}
+
+// HandleGetRegion serves a region of a level, in the coordinates of the level 0, as JPEG or PNG.
+func (t *FileHandlers) HandleGetRegion(c *gin.Context) {
+	tiffFile, params, err := handleRegionParams(c)
+	if err != nil {
+		slog.Error("Invalid region request", "file", tiffFile, "error", err)
+		respondError(c, fmt.Errorf("%w: %w", ErrBadRequest, err), "Invalid request")
+		return
+	}
+
+	reader, _, err := t.getReader(tiffFile)
+	if err != nil {
+		slog.Error("Error opening file", "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to open file")
+		return
+	}
+	defer reader.Release()
+
+	img, err := reader.ReadRegion(params.level, params.x, params.y, params.width, params.height)
+	if err != nil {
+		slog.Error("Error while reading region", "params", params, "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to read region")
+		return
+	}
+	imageData, contentType, err := encodeImage(img, params.format)
+	if err != nil {
+		slog.Error("Error while encoding region", "file", tiffFile, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode region"})
 		return
 	}
 
-	c.Data(http.StatusOK, "image/jpeg", imageData)
+	c.Data(http.StatusOK, contentType, imageData)
 }
 
 func (t *FileHandlers) HandleOpenFile(c *gin.Context) {
@@ -58,47 +186,78 @@ func (t *FileHandlers) HandleOpenFile(c *gin.Context) {
 	// Encode the resource path in a URL-friendly format
 	encoded := base62.EncodeToString([]byte(tiffFile))
 
-	if _, metadata, ok := t.cache.Get(tiffFile); ok {
+	if reader, metadata, ok := t.cache.Get(t.cacheKey(tiffFile)); ok {
+		defer reader.Release()
 		c.JSON(200, gin.H{
-			"encoded":  encoded,
-			"decoded":  tiffFile,
-			"metadata": metadata,
+			"encoded":    encoded,
+			"decoded":    tiffFile,
+			"vendor":     reader.GetVendor(),
+			"metadata":   metadata,
+			"properties": reader.Properties(),
 		})
 		return
 	}
 
-	_, metadata, err := t.openFileReader(tiffFile)
+	reader, metadata, err := t.openFileReader(tiffFile)
 	if err != nil {
 		slog.Error("Error opening file", "file", tiffFile, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		respondError(c, err, "Failed to open file")
 		return
 	}
+	defer reader.Release()
 
 	c.JSON(200, gin.H{
-		"encoded":  encoded,
-		"decoded":  tiffFile,
-		"metadata": metadata,
+		"encoded":    encoded,
+		"decoded":    tiffFile,
+		"vendor":     reader.GetVendor(),
+		"metadata":   metadata,
+		"properties": reader.Properties(),
 	})
 }
 
-func (t *FileHandlers) openFileReader(tiffFile string) (*slides.SlideReader, *slides.PyramidMetadata, error) {
-	// Determine the full path to the underlying resource (file) to be accessed
-	name := fmt.Sprintf("%s/%s", t.assetsDirectory, tiffFile)
+// getReader returns the cached reader of the file and its metadata, opening it if needed,
+// the reader being retained until the caller releases it.
+func (t *FileHandlers) getReader(tiffFile string) (*slide.SlideReader, *slide.PyramidMetadata, error) {
+	if reader, metadata, ok := t.cache.Get(t.cacheKey(tiffFile)); ok {
+		return reader, metadata, nil
+	}
+	return t.openFileReader(tiffFile)
+}
+
+// cacheKey keeps the local files apart from the objects and URLs sharing the cache.
+func (t *FileHandlers) cacheKey(tiffFile string) string {
+	return "file://" + tiffFile
+}
+
+func (t *FileHandlers) openFileReader(tiffFile string) (*slide.SlideReader, *slide.PyramidMetadata, error) {
+	// Determine the full path to the underlying resource (file), confined to the assets roots
+	name, err := t.assets.Resolve(tiffFile)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Open the resource and retrieve its metadata
-	reader := slides.NewSlideReader()
-	err := reader.OpenFile(name)
+	reader := slide.NewSlideReader()
+	reader.SetFillColor(t.fill)
+	switch t.backend {
+	case BackendMmap:
+		err = reader.OpenMmap(name)
+	default:
+		err = reader.OpenFile(name)
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to open image file: %w", err)
 	}
 
 	metadata, err := reader.GetMetadata()
 	if err != nil {
+		reader.Close()
 		return nil, nil, fmt.Errorf("failed to read metadata from: %w", err)
 	}
 
-	// put in memory cache
-	t.cache.Set(tiffFile, reader, &metadata)
+	// put in memory cache, the reader being retained for the caller
+	reader.Retain()
+	t.cache.Set(t.cacheKey(tiffFile), reader, &metadata)
 
 	return reader, &metadata, nil
 }
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseFillColor(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    color.Color
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"ffffff", color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, false},
+		{"#F0E0D0", color.NRGBA{R: 0xf0, G: 0xe0, B: 0xd0, A: 0xff}, false},
+		{"ff000080", color.NRGBA{R: 0xff, A: 0x80}, false},
+		{"fff", nil, true},
+		{"white", nil, true},
+		{"ff00000080", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseFillColor(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseFillColor(%q) = %v, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFillColor(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFillColor(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
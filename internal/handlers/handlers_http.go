@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/slide"
+	"github.com/gin-gonic/gin"
+	"github.com/jxskiss/base62"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type HTTPHandlers struct {
+	cache  *SlideReaderCache
+	hosts  *HostAllowList
+	client *http.Client
+}
+
+// NewHTTPHandlers creates the handlers of the remote slides, restricted to the hosts of the list,
+// each request being bounded by timeout.
+func NewHTTPHandlers(cache *SlideReaderCache, hosts *HostAllowList, timeout time.Duration) *HTTPHandlers {
+	return &HTTPHandlers{
+		cache:  cache,
+		hosts:  hosts,
+		client: hosts.Client(timeout),
+	}
+}
+
+func (t *HTTPHandlers) HandleGetTile(c *gin.Context) {
+	url, levelIdx, x, y, err := handleTileParams(c)
+	if err != nil {
+		slog.Error("Error opening URL", "url", url, "error", err)
+		respondError(c, fmt.Errorf("%w: %w", ErrBadRequest, err), "Invalid request")
+		return
+	}
+
+	reader, metadata, ok := t.cache.Get(t.cacheKey(url))
+	if !ok {
+		reader, metadata, err = t.openURLReader(url)
+		if err != nil {
+			slog.Error("Error opening URL", "url", url, "error", err)
+			respondError(c, err, "Failed to open URL")
+			return
+		}
+	}
+	defer reader.Release()
+
+	tileIdx, err := metadata.TileIndex(levelIdx, x, y)
+	if err != nil {
+		respondError(c, err, "Invalid tile")
+		return
+	}
+	imageData, err := reader.GetTile(levelIdx, tileIdx)
+	if err != nil {
+		slog.Error("Error while serving tile", "levelIdx", levelIdx, "x", x, "y", y, "url", url, "error", err)
+		respondError(c, err, "Failed to read tile")
+		return
+	}
+
+	c.Data(http.StatusOK, http.DetectContentType(imageData), imageData)
+}
+
+func (t *HTTPHandlers) HandleOpenHTTP(c *gin.Context) {
+	url, err := remoteURL(c)
+	if err != nil {
+		respondError(c, fmt.Errorf("%w: %w", ErrBadRequest, err), "Invalid request")
+		return
+	}
+
+	// Encode the URL in a URL-friendly format
+	encoded := base62.EncodeToString([]byte(url))
+
+	if reader, metadata, ok := t.cache.Get(t.cacheKey(url)); ok {
+		defer reader.Release()
+		c.JSON(200, gin.H{
+			"encoded":    encoded,
+			"decoded":    url,
+			"vendor":     reader.GetVendor(),
+			"metadata":   metadata,
+			"properties": reader.Properties(),
+		})
+		return
+	}
+
+	reader, metadata, err := t.openURLReader(url)
+	if err != nil {
+		slog.Error("Error opening URL", "url", url, "error", err)
+		respondError(c, err, "Failed to open URL")
+		return
+	}
+	defer reader.Release()
+
+	c.JSON(200, gin.H{
+		"encoded":    encoded,
+		"decoded":    url,
+		"vendor":     reader.GetVendor(),
+		"metadata":   metadata,
+		"properties": reader.Properties(),
+	})
+}
+
+// cacheKey distinguishes remote slides from local files and S3 objects sharing the same SlideReaderCache.
+func (t *HTTPHandlers) cacheKey(url string) string {
+	return "url:" + url
+}
+
+func (t *HTTPHandlers) openURLReader(url string) (*slide.SlideReader, *slide.PyramidMetadata, error) {
+	if err := t.hosts.Check(url); err != nil {
+		return nil, nil, err
+	}
+
+	// Open the remote resource and retrieve its metadata
+	reader := slide.NewSlideReader()
+	err := reader.OpenURLWithClient(t.client, url)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open remote file: %w", err)
+	}
+
+	metadata, err := reader.GetMetadata()
+	if err != nil {
+		reader.Close()
+		return nil, nil, fmt.Errorf("failed to read metadata from: %w", err)
+	}
+
+	// put in memory cache, the reader being retained for the caller
+	reader.Retain()
+	t.cache.Set(t.cacheKey(url), reader, &metadata)
+
+	return reader, &metadata, nil
+}
+
+// remoteURL rebuilds the target URL from the wildcard parameter, i.e. /open/http/https://host/slide.svs?sig=...
+func remoteURL(c *gin.Context) (string, error) {
+	url := strings.TrimPrefix(c.Param("url"), "/")
+
+	// path cleaning by proxies may collapse the double slash after the scheme
+	for _, scheme := range []string{"http:", "https:"} {
+		if rest, ok := strings.CutPrefix(url, scheme); ok {
+			url = scheme + "//" + strings.TrimLeft(rest, "/")
+			if c.Request.URL.RawQuery != "" {
+				url += "?" + c.Request.URL.RawQuery
+			}
+			return url, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported URL, expected http:// or https://")
+}
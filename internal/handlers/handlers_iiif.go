@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jxskiss/base62"
+	"image"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+)
+
+// iiifMaxReadPixels limits the size of the region read from the best level, before it is scaled to the requested size.
+const iiifMaxReadPixels = 4 * maxRegionPixels
+
+// IIIFHandlers serve the local slides with the IIIF Image API 3.0, for Mirador and the IIIF viewers:
+// /iiif/3/<encoded>/info.json and /iiif/3/<encoded>/{region}/{size}/{rotation}/{quality}.{format}.
+type IIIFHandlers struct {
+	files *FileHandlers
+}
+
+func NewIIIFHandlers(files *FileHandlers) *IIIFHandlers {
+	return &IIIFHandlers{
+		files: files,
+	}
+}
+
+// HandleRedirectInfo redirects the base URI of an image to its information.
+func (t *IIIFHandlers) HandleRedirectInfo(c *gin.Context) {
+	c.Redirect(http.StatusSeeOther, c.Request.URL.Path+"/info.json")
+}
+
+// HandleGetInfo serves the information of an image: its dimensions, tiles and supported features.
+func (t *IIIFHandlers) HandleGetInfo(c *gin.Context) {
+	tiffFile, err := iiifIdentifier(c)
+	if err != nil {
+		respondError(c, fmt.Errorf("%w: %w", ErrBadRequest, err), "Invalid request")
+		return
+	}
+	reader, metadata, err := t.files.getReader(tiffFile)
+	if err != nil {
+		slog.Error("Error opening file", "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to open file")
+		return
+	}
+	defer reader.Release()
+
+	level0 := metadata.Levels[0]
+	var sizes []gin.H
+	var scaleFactors []int
+	for i := len(metadata.Levels) - 1; i >= 0; i-- {
+		level := metadata.Levels[i]
+		sizes = append(sizes, gin.H{"width": level.ImageWidth, "height": level.ImageHeight})
+		if factor := int(math.Round(level.Downsample)); !slices.Contains(scaleFactors, factor) {
+			scaleFactors = append(scaleFactors, factor)
+		}
+	}
+	slices.Sort(scaleFactors)
+
+	c.Header("Link", fmt.Sprintf(`<%s/%s.json>;rel="profile"`, iiifProtocol+"/3", iiifProfile))
+	c.Header("Content-Type", `application/ld+json;profile="`+iiifContext+`"`)
+	c.JSON(http.StatusOK, gin.H{
+		"@context": iiifContext,
+		"id":       iiifBaseURI(c),
+		"type":     "ImageService3",
+		"protocol": iiifProtocol,
+		"profile":  iiifProfile,
+		"width":    level0.ImageWidth,
+		"height":   level0.ImageHeight,
+		"maxArea":  maxRegionPixels,
+		"sizes":    sizes,
+		"tiles": []gin.H{{
+			"width":        level0.TileWidth,
+			"height":       level0.TileHeight,
+			"scaleFactors": scaleFactors,
+		}},
+		"extraQualities": []string{iiifQualityColor, iiifQualityGray, iiifQualityBitonal},
+		"extraFormats":   []string{"webp"},
+		"extraFeatures":  []string{"mirroring", "rotationBy90s", "sizeUpscaling"},
+	})
+}
+
+// HandleGetImage serves a region of an image, read from the pyramid level of the closest resolution.
+func (t *IIIFHandlers) HandleGetImage(c *gin.Context) {
+	tiffFile, err := iiifIdentifier(c)
+	if err != nil {
+		respondError(c, fmt.Errorf("%w: %w", ErrBadRequest, err), "Invalid request")
+		return
+	}
+	reader, metadata, err := t.files.getReader(tiffFile)
+	if err != nil {
+		slog.Error("Error opening file", "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to open file")
+		return
+	}
+	defer reader.Release()
+
+	level0 := metadata.Levels[0]
+	bounds := image.Pt(level0.ImageWidth, level0.ImageHeight)
+	request, err := parseIIIFRequest(bounds, c.Param("region"), c.Param("size"), c.Param("rotation"), c.Param("file"))
+	if err != nil {
+		respondError(c, fmt.Errorf("%w: %w", ErrBadRequest, err), "Invalid request")
+		return
+	}
+
+	// region of the best level, scaled afterward to the requested size
+	region := request.region
+	downsample := min(float64(region.Dx())/float64(request.size.X), float64(region.Dy())/float64(request.size.Y))
+	levelIdx := metadata.BestLevel(downsample)
+	level := metadata.Levels[levelIdx]
+	scaleX := float64(level.ImageWidth) / float64(level0.ImageWidth)
+	scaleY := float64(level.ImageHeight) / float64(level0.ImageHeight)
+	width := max(1, min(level.ImageWidth-int(float64(region.Min.X)*scaleX), int(math.Ceil(float64(region.Dx())*scaleX))))
+	height := max(1, min(level.ImageHeight-int(float64(region.Min.Y)*scaleY), int(math.Ceil(float64(region.Dy())*scaleY))))
+	if width*height > iiifMaxReadPixels {
+		respondError(c, fmt.Errorf("%w: region too large for the pyramid levels", ErrBadRequest), "Invalid request")
+		return
+	}
+
+	img, err := reader.ReadRegion(levelIdx, region.Min.X, region.Min.Y, width, height)
+	if err != nil {
+		slog.Error("Error while reading IIIF region", "region", region, "level", levelIdx, "file", tiffFile, "error", err)
+		respondError(c, err, "Failed to read region")
+		return
+	}
+	imageData, contentType, err := encodeImage(transformIIIF(img, request), request.format)
+	if err != nil {
+		slog.Error("Error while encoding IIIF image", "file", tiffFile, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode image"})
+		return
+	}
+
+	c.Header("Link", fmt.Sprintf(`<%s/%s.json>;rel="profile"`, iiifProtocol+"/3", iiifProfile))
+	c.Data(http.StatusOK, contentType, imageData)
+}
+
+// iiifIdentifier decodes the identifier of the image, the base62 encoded path of the slide.
+func iiifIdentifier(c *gin.Context) (string, error) {
+	decoded, err := base62.DecodeString(c.Param("id"))
+	if err != nil {
+		return "", fmt.Errorf("failed to base62 decode identifier")
+	}
+	return string(decoded), nil
+}
+
+// iiifBaseURI returns the URI of the image service, as requested by the client.
+func iiifBaseURI(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return fmt.Sprintf("%s://%s/iiif/3/%s", scheme, c.Request.Host, c.Param("id"))
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxRedirects is the number of redirects followed when opening a remote slide, as the default http.Client does.
+const maxRedirects = 10
+
+// ErrForbiddenURL is returned for the remote URLs whose host is not allowed.
+var ErrForbiddenURL = errors.New("forbidden URL")
+
+// HostAllowList restricts the hosts of the remote slides opened by the HTTP handlers, so that the server cannot be
+// used to reach internal services. A pattern is a host name ("slides.example.com"), a domain with its subdomains
+// ("*.example.com") or an IP address, an empty list allowing no host.
+// The redirects are checked as well, and the loopback, private (RFC 1918, ULA), link-local (cloud metadata) and
+// unspecified addresses are refused when dialing, whatever the host name resolving to them, unless they are listed
+// as IP addresses.
+type HostAllowList struct {
+	patterns []string // lower case
+}
+
+func NewHostAllowList(patterns []string) *HostAllowList {
+	list := &HostAllowList{}
+	for _, pattern := range patterns {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			list.patterns = append(list.patterns, pattern)
+		}
+	}
+	return list
+}
+
+// Check returns an error wrapping ErrForbiddenURL unless rawURL is an http(s) URL of an allowed host.
+func (h *HostAllowList) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrForbiddenURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported scheme %q", ErrForbiddenURL, u.Scheme)
+	}
+	if !h.allowedHost(u.Hostname()) {
+		return fmt.Errorf("%w: host %q is not allowed", ErrForbiddenURL, u.Hostname())
+	}
+	return nil
+}
+
+// Client returns an HTTP client checking its redirects and the addresses it dials against the list.
+func (h *HostAllowList) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: h.checkAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // the addresses dialed must be the ones of the slides
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return h.Check(req.URL.String())
+		},
+	}
+}
+
+func (h *HostAllowList) allowedHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range h.patterns {
+		if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAddress refuses to dial the internal addresses which are not explicitly allowed.
+func (h *HostAllowList) checkAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrForbiddenURL, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: invalid address %q", ErrForbiddenURL, host)
+	}
+	internal := ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+	if internal && !h.allowedHost(ip.String()) {
+		return fmt.Errorf("%w: internal address %s", ErrForbiddenURL, ip)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHostAllowListCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		url      string
+		allowed  bool
+	}{
+		{"exact host", []string{"slides.example.com"}, "https://slides.example.com/a.svs", true},
+		{"exact host with port", []string{"slides.example.com"}, "http://slides.example.com:8080/a.svs", true},
+		{"case insensitive", []string{" Slides.Example.com "}, "https://SLIDES.example.com/a.svs", true},
+		{"other host", []string{"slides.example.com"}, "https://evil.example.com/a.svs", false},
+		{"suffix is not a subdomain", []string{"example.com"}, "https://slides.example.com/a.svs", false},
+		{"wildcard subdomain", []string{"*.example.com"}, "https://a.b.example.com/a.svs", true},
+		{"wildcard domain itself", []string{"*.example.com"}, "https://example.com/a.svs", true},
+		{"wildcard other domain", []string{"*.example.com"}, "https://example.com.evil.org/a.svs", false},
+		{"IP address", []string{"10.0.0.5"}, "http://10.0.0.5/a.svs", true},
+		{"metadata address", []string{"*.example.com"}, "http://169.254.169.254/latest", false},
+		{"unsupported scheme", []string{"slides.example.com"}, "file://slides.example.com/etc/passwd", false},
+		{"empty list", nil, "https://slides.example.com/a.svs", false},
+		{"invalid URL", []string{"slides.example.com"}, "http://slides.example.com/%zz", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewHostAllowList(tt.patterns).Check(tt.url)
+			if tt.allowed && err != nil {
+				t.Fatalf("Check(%q) = %v, want allowed", tt.url, err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrForbiddenURL) {
+				t.Fatalf("Check(%q) = %v, want %v", tt.url, err, ErrForbiddenURL)
+			}
+		})
+	}
+}
+
+func TestHostAllowListCheckAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		address  string
+		allowed  bool
+	}{
+		{"public address", []string{"slides.example.com"}, "93.184.216.34:443", true},
+		{"loopback", []string{"slides.example.com"}, "127.0.0.1:80", false},
+		{"IPv6 loopback", []string{"slides.example.com"}, "[::1]:80", false},
+		{"link-local", []string{"slides.example.com"}, "169.254.169.254:80", false},
+		{"unspecified", []string{"slides.example.com"}, "0.0.0.0:80", false},
+		{"private class A", []string{"*.example.com"}, "10.0.12.7:443", false},
+		{"private class B", []string{"*.example.com"}, "172.16.0.1:443", false},
+		{"private class C", []string{"*.example.com"}, "192.168.1.10:80", false},
+		{"unique local IPv6", []string{"*.example.com"}, "[fd00::1]:443", false},
+		{"listed loopback", []string{"127.0.0.1"}, "127.0.0.1:9000", true},
+		{"listed private", []string{"10.0.12.7"}, "10.0.12.7:443", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewHostAllowList(tt.patterns).checkAddress("tcp", tt.address, nil)
+			if (err == nil) != tt.allowed {
+				t.Fatalf("checkAddress(%q) = %v, want allowed %v", tt.address, err, tt.allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"fmt"
+	xdraw "golang.org/x/image/draw"
+	"image"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Parameters of the IIIF Image API 3.0 requests: {region}/{size}/{rotation}/{quality}.{format}
+// See https://iiif.io/api/image/3.0/
+const (
+	iiifContext  = "http://iiif.io/api/image/3/context.json"
+	iiifProtocol = "http://iiif.io/api/image"
+	iiifProfile  = "level2"
+
+	iiifQualityDefault = "default"
+	iiifQualityColor   = "color"
+	iiifQualityGray    = "gray"
+	iiifQualityBitonal = "bitonal"
+)
+
+// iiifFormats are the image formats served, keyed by their IIIF extension.
+var iiifFormats = map[string]string{
+	"jpg":  "jpeg",
+	"png":  "png",
+	"webp": "webp",
+}
+
+// iiifRequest is a parsed IIIF image request, the region being in the coordinates of the level 0.
+type iiifRequest struct {
+	region   image.Rectangle
+	size     image.Point
+	rotation int // clockwise, in degrees: 0, 90, 180 or 270
+	mirror   bool
+	quality  string
+	format   string // jpeg, png or webp
+}
+
+// parseIIIFRequest parses the parameters of an image request on an image of the given dimensions.
+func parseIIIFRequest(bounds image.Point, region, size, rotation, file string) (iiifRequest, error) {
+	var request iiifRequest
+	var err error
+	if request.region, err = parseIIIFRegion(bounds, region); err != nil {
+		return request, err
+	}
+	if request.size, err = parseIIIFSize(request.region.Size(), size); err != nil {
+		return request, err
+	}
+
+	request.mirror = strings.HasPrefix(rotation, "!")
+	degrees, err := strconv.Atoi(strings.TrimPrefix(rotation, "!"))
+	if err != nil || degrees < 0 || degrees >= 360 || degrees%90 != 0 {
+		return request, fmt.Errorf("invalid rotation %s, multiples of 90 supported", rotation)
+	}
+	request.rotation = degrees
+
+	quality, extension, ok := strings.Cut(file, ".")
+	if !ok {
+		return request, fmt.Errorf("invalid %s, expected {quality}.{format}", file)
+	}
+	switch quality {
+	case iiifQualityDefault, iiifQualityColor, iiifQualityGray, iiifQualityBitonal:
+		request.quality = quality
+	default:
+		return request, fmt.Errorf("invalid quality %s", quality)
+	}
+	if request.format, ok = iiifFormats[extension]; !ok {
+		return request, fmt.Errorf("invalid format %s, expected jpg, png or webp", extension)
+	}
+	return request, nil
+}
+
+// parseIIIFRegion parses full, square, x,y,w,h and pct:x,y,w,h, the region being clipped to the image.
+func parseIIIFRegion(bounds image.Point, region string) (image.Rectangle, error) {
+	full := image.Rectangle{Max: bounds}
+	var rect image.Rectangle
+	switch {
+	case region == "full":
+		return full, nil
+	case region == "square":
+		side := min(bounds.X, bounds.Y)
+		origin := image.Pt((bounds.X-side)/2, (bounds.Y-side)/2)
+		return image.Rectangle{Min: origin, Max: origin.Add(image.Pt(side, side))}, nil
+	case strings.HasPrefix(region, "pct:"):
+		values, err := parseIIIFFloats(strings.TrimPrefix(region, "pct:"), 4)
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("invalid region %s: %w", region, err)
+		}
+		x, y := int(math.Round(values[0]*float64(bounds.X)/100)), int(math.Round(values[1]*float64(bounds.Y)/100))
+		w, h := int(math.Round(values[2]*float64(bounds.X)/100)), int(math.Round(values[3]*float64(bounds.Y)/100))
+		rect = image.Rect(x, y, x+w, y+h)
+	default:
+		values, err := parseIIIFFloats(region, 4)
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("invalid region %s: %w", region, err)
+		}
+		for _, v := range values {
+			if v != math.Trunc(v) {
+				return image.Rectangle{}, fmt.Errorf("invalid region %s: integers expected", region)
+			}
+		}
+		x, y, w, h := int(values[0]), int(values[1]), int(values[2]), int(values[3])
+		rect = image.Rect(x, y, x+w, y+h)
+	}
+	if rect.Dx() <= 0 || rect.Dy() <= 0 {
+		return image.Rectangle{}, fmt.Errorf("invalid region %s: empty", region)
+	}
+	rect = rect.Intersect(full)
+	if rect.Empty() {
+		return image.Rectangle{}, fmt.Errorf("invalid region %s: outside of the image", region)
+	}
+	return rect, nil
+}
+
+// parseIIIFSize parses max, w,, ,h, pct:n, w,h and !w,h, prefixed by ^ to allow upscaling,
+// the size being limited to maxRegionPixels.
+func parseIIIFSize(region image.Point, size string) (image.Point, error) {
+	upscale := strings.HasPrefix(size, "^")
+	spec := strings.TrimPrefix(size, "^")
+	rw, rh := float64(region.X), float64(region.Y)
+
+	var w, h float64
+	switch {
+	case spec == "max":
+		w, h = rw, rh
+		if area := w * h; area > maxRegionPixels {
+			scale := math.Sqrt(maxRegionPixels / area)
+			w, h = w*scale, h*scale
+		}
+	case strings.HasPrefix(spec, "pct:"):
+		values, err := parseIIIFFloats(strings.TrimPrefix(spec, "pct:"), 1)
+		if err != nil || values[0] <= 0 {
+			return image.Point{}, fmt.Errorf("invalid size %s", size)
+		}
+		w, h = rw*values[0]/100, rh*values[0]/100
+	case strings.HasPrefix(spec, "!"):
+		values, err := parseIIIFFloats(strings.TrimPrefix(spec, "!"), 2)
+		if err != nil || values[0] <= 0 || values[1] <= 0 {
+			return image.Point{}, fmt.Errorf("invalid size %s", size)
+		}
+		scale := min(values[0]/rw, values[1]/rh)
+		if !upscale {
+			scale = min(scale, 1)
+		}
+		w, h = rw*scale, rh*scale
+	default:
+		width, height, ok := strings.Cut(spec, ",")
+		if !ok || (width == "" && height == "") {
+			return image.Point{}, fmt.Errorf("invalid size %s", size)
+		}
+		var err error
+		if width != "" {
+			if w, err = strconv.ParseFloat(width, 64); err != nil || w <= 0 {
+				return image.Point{}, fmt.Errorf("invalid size %s", size)
+			}
+		}
+		if height != "" {
+			if h, err = strconv.ParseFloat(height, 64); err != nil || h <= 0 {
+				return image.Point{}, fmt.Errorf("invalid size %s", size)
+			}
+		}
+		switch {
+		case width == "":
+			w = rw * h / rh
+		case height == "":
+			h = rh * w / rw
+		}
+	}
+
+	result := image.Pt(max(1, int(math.Round(w))), max(1, int(math.Round(h))))
+	if !upscale && (result.X > region.X || result.Y > region.Y) {
+		return image.Point{}, fmt.Errorf("invalid size %s: larger than the region, ^ expected", size)
+	}
+	if result.X > maxRegionPixels/result.Y {
+		return image.Point{}, fmt.Errorf("invalid size %s: more than %d pixels", size, maxRegionPixels)
+	}
+	return result, nil
+}
+
+func parseIIIFFloats(value string, count int) ([]float64, error) {
+	fields := strings.Split(value, ",")
+	if len(fields) != count {
+		return nil, fmt.Errorf("%d values expected", count)
+	}
+	values := make([]float64, count)
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil || v < 0 || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid value %s", field)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// transformIIIF scales the region to the requested size, then mirrors, rotates and converts it to the quality.
+func transformIIIF(img image.Image, request iiifRequest) image.Image {
+	if img.Bounds().Size() != request.size {
+		scaled := image.NewRGBA(image.Rectangle{Max: request.size})
+		xdraw.BiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+		img = scaled
+	}
+	if request.mirror || request.rotation != 0 {
+		img = rotateImage(img, request.rotation, request.mirror)
+	}
+
+	switch request.quality {
+	case iiifQualityGray, iiifQualityBitonal:
+		gray := image.NewGray(img.Bounds())
+		draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+		if request.quality == iiifQualityBitonal {
+			for i, v := range gray.Pix {
+				gray.Pix[i] = 0
+				if v >= 128 {
+					gray.Pix[i] = 255
+				}
+			}
+		}
+		return gray
+	}
+	return img
+}
+
+// rotateImage mirrors the image horizontally if requested, then rotates it clockwise by degrees, a multiple of 90.
+func rotateImage(img image.Image, degrees int, mirror bool) image.Image {
+	src, ok := img.(*image.RGBA)
+	if !ok || src.Bounds().Min != (image.Point{}) {
+		src = image.NewRGBA(image.Rectangle{Max: img.Bounds().Size()})
+		draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	size := image.Pt(w, h)
+	if degrees == 90 || degrees == 270 {
+		size = image.Pt(h, w)
+	}
+
+	rotated := image.NewRGBA(image.Rectangle{Max: size})
+	for y := range h {
+		for x := range w {
+			sx := x
+			if mirror {
+				sx = w - 1 - x
+			}
+			var dx, dy int
+			switch degrees {
+			case 90:
+				dx, dy = h-1-y, x
+			case 180:
+				dx, dy = w-1-x, h-1-y
+			case 270:
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			copy(rotated.Pix[rotated.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(sx, y):][:4])
+		}
+	}
+	return rotated
+}
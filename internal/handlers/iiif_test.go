@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParseIIIFRegion(t *testing.T) {
+	bounds := image.Pt(1000, 800)
+	tests := []struct {
+		name    string
+		region  string
+		want    image.Rectangle
+		wantErr bool
+	}{
+		{"full", "full", image.Rect(0, 0, 1000, 800), false},
+		{"square", "square", image.Rect(100, 0, 900, 800), false},
+		{"pixels", "10,20,30,40", image.Rect(10, 20, 40, 60), false},
+		{"pixels clipped", "900,700,200,200", image.Rect(900, 700, 1000, 800), false},
+		{"percent", "pct:10,10,50,50", image.Rect(100, 80, 600, 480), false},
+		{"percent clipped", "pct:50,50,100,100", image.Rect(500, 400, 1000, 800), false},
+		{"empty width", "10,10,0,10", image.Rectangle{}, true},
+		{"empty percent height", "pct:10,10,10,0", image.Rectangle{}, true},
+		{"outside of the image", "1000,0,10,10", image.Rectangle{}, true},
+		{"non integer pixels", "1.5,0,10,10", image.Rectangle{}, true},
+		{"negative origin", "-1,0,10,10", image.Rectangle{}, true},
+		{"three values", "0,0,10", image.Rectangle{}, true},
+		{"infinite percent", "pct:0,0,Inf,10", image.Rectangle{}, true},
+		{"unknown", "half", image.Rectangle{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIIIFRegion(bounds, tt.region)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIIIFRegion(%s): %v, want error %t", tt.region, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseIIIFRegion(%s) = %v, want %v", tt.region, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIIIFSize(t *testing.T) {
+	region := image.Pt(1000, 800)
+	tests := []struct {
+		name    string
+		region  image.Point
+		size    string
+		want    image.Point
+		wantErr bool
+	}{
+		{"max", region, "max", image.Pt(1000, 800), false},
+		{"max limited to maxRegionPixels", image.Pt(8192, 8192), "max", image.Pt(4096, 4096), false},
+		{"width", region, "500,", image.Pt(500, 400), false},
+		{"height", region, ",400", image.Pt(500, 400), false},
+		{"percent", region, "pct:50", image.Pt(500, 400), false},
+		{"distorted", region, "500,500", image.Pt(500, 500), false},
+		{"best fit", region, "!500,500", image.Pt(500, 400), false},
+		{"best fit not upscaled", region, "!2000,2000", image.Pt(1000, 800), false},
+		{"best fit upscaled", region, "^!2000,2000", image.Pt(2000, 1600), false},
+		{"width upscaled", region, "^1200,", image.Pt(1200, 960), false},
+		{"percent upscaled", region, "^pct:200", image.Pt(2000, 1600), false},
+		{"at least a pixel", region, "pct:0.01", image.Pt(1, 1), false},
+		{"width larger than the region", region, "1200,", image.Point{}, true},
+		{"percent larger than the region", region, "pct:200", image.Point{}, true},
+		{"more than maxRegionPixels", region, "^5000,5000", image.Point{}, true},
+		{"largest upscaled", region, "^4096,4096", image.Pt(4096, 4096), false},
+		{"no dimension", region, ",", image.Point{}, true},
+		{"zero width", region, "0,", image.Point{}, true},
+		{"zero best fit", region, "!0,10", image.Point{}, true},
+		{"zero percent", region, "pct:0", image.Point{}, true},
+		{"unknown", region, "full", image.Point{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIIIFSize(tt.region, tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIIIFSize(%v, %s): %v, want error %t", tt.region, tt.size, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseIIIFSize(%v, %s) = %v, want %v", tt.region, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotateImage(t *testing.T) {
+	// 2x3 pixels, the red of the pixel x, y being 10*y+x
+	src := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	for y := range 3 {
+		for x := range 2 {
+			src.Set(x, y, color.RGBA{R: uint8(10*y + x), A: 0xff})
+		}
+	}
+	offset := image.NewRGBA(image.Rect(0, 0, 3, 4))
+	for y := range 3 {
+		for x := range 2 {
+			offset.Set(x+1, y+1, src.At(x, y))
+		}
+	}
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		degrees int
+		mirror  bool
+		want    [][]uint8 // rows of the red of the pixels
+	}{
+		{"0", src, 0, false, [][]uint8{{0, 1}, {10, 11}, {20, 21}}},
+		{"90", src, 90, false, [][]uint8{{20, 10, 0}, {21, 11, 1}}},
+		{"180", src, 180, false, [][]uint8{{21, 20}, {11, 10}, {1, 0}}},
+		{"270", src, 270, false, [][]uint8{{1, 11, 21}, {0, 10, 20}}},
+		{"mirror", src, 0, true, [][]uint8{{1, 0}, {11, 10}, {21, 20}}},
+		{"mirror 90", src, 90, true, [][]uint8{{21, 11, 1}, {20, 10, 0}}},
+		{"mirror 180", src, 180, true, [][]uint8{{20, 21}, {10, 11}, {0, 1}}},
+		{"mirror 270", src, 270, true, [][]uint8{{0, 10, 20}, {1, 11, 21}}},
+		{"not at the origin", offset.SubImage(image.Rect(1, 1, 3, 4)), 90, false, [][]uint8{{20, 10, 0}, {21, 11, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rotateImage(tt.img, tt.degrees, tt.mirror)
+			if size := got.Bounds().Size(); size != image.Pt(len(tt.want[0]), len(tt.want)) {
+				t.Fatalf("rotateImage: %v pixels, want %dx%d", size, len(tt.want[0]), len(tt.want))
+			}
+			for y, row := range tt.want {
+				for x, want := range row {
+					if r, _, _, _ := got.At(x, y).RGBA(); uint8(r>>8) != want {
+						t.Errorf("pixel (%d,%d) of red %d, want %d", x, y, r>>8, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+// Package jpeg manipulates the segments of JPEG streams,
+// i.e. to rebuild standalone JPEG tiles from abbreviated TIFF tiles and their JPEGTables.
+//
+// The exported API of this package follows semantic versioning.
+package jpeg
@@ -1,4 +1,4 @@
-package jpegio
+package jpeg
 
 type JpegBinBlock []byte
 
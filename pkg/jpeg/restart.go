@@ -0,0 +1,108 @@
+package jpeg
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// RestartScan is the header of a JPEG whose entropy-coded data is split by restart markers (RSTn),
+// as the huge strips of the Hamamatsu NDPI files.
+// Each restart interval is decoded independently of the others: any sequence of intervals covering
+// whole MCU rows of the result can be assembled into a smaller JPEG, without decoding the image.
+type RestartScan struct {
+	Header          []byte // segments from SOI to SOS, both included
+	McuWidth        int    // size of a MCU in pixels
+	McuHeight       int    //
+	RestartInterval int    // number of MCUs of each interval
+	sofOffset       int    // offset of the SOF segment in the header
+}
+
+// ParseRestartScan parses the segments preceding the entropy-coded data of a JPEG.
+func ParseRestartScan(header []byte) (RestartScan, error) {
+	if !isSOI(header) {
+		return RestartScan{}, fmt.Errorf("invalid JPEG format: missing SOI marker")
+	}
+
+	scan := RestartScan{sofOffset: -1}
+	offset := 2
+	for offset+4 <= len(header) {
+		if header[offset] != 0xFF {
+			return RestartScan{}, fmt.Errorf("invalid JPEG format: no marker at %d", offset)
+		}
+		size, segment := jpegSegment(header[offset:])
+		if offset+size+2 > len(header) {
+			return RestartScan{}, fmt.Errorf("invalid JPEG format: truncated segment at %d", offset)
+		}
+
+		switch {
+		case isSOF(segment):
+			if err := scan.decodeSampling(segment); err != nil {
+				return RestartScan{}, err
+			}
+			scan.sofOffset = offset
+		case isDRI(segment):
+			if len(segment) < 6 {
+				return RestartScan{}, fmt.Errorf("DRI segment too short")
+			}
+			scan.RestartInterval = int(binary.BigEndian.Uint16(segment[4:6]))
+		case isSOS(segment):
+			if scan.sofOffset < 0 {
+				return RestartScan{}, fmt.Errorf("invalid JPEG format: missing SOF marker")
+			}
+			if scan.RestartInterval == 0 {
+				return RestartScan{}, fmt.Errorf("invalid JPEG format: no restart interval")
+			}
+			scan.Header = header[:offset+size+2]
+			return scan, nil
+		}
+		offset += size + 2
+	}
+	return RestartScan{}, fmt.Errorf("invalid JPEG format: missing SOS marker")
+}
+
+// decodeSampling computes the size of a MCU from the sampling factors of the components.
+func (s *RestartScan) decodeSampling(sofSegment []byte) error {
+	if len(sofSegment) < 10 {
+		return fmt.Errorf("SOF segment too short")
+	}
+	components := int(sofSegment[9])
+	if len(sofSegment) < 10+3*components {
+		return fmt.Errorf("SOF segment too short for %d components", components)
+	}
+	maxH, maxV := 1, 1
+	for i := range components {
+		sampling := sofSegment[10+3*i+1]
+		maxH, maxV = max(maxH, int(sampling>>4)), max(maxV, int(sampling&0x0F))
+	}
+	s.McuWidth, s.McuHeight = 8*maxH, 8*maxV
+	return nil
+}
+
+// Compose assembles the restart intervals into a JPEG of the given size, each interval being a row of MCUs.
+// The intervals hold their entropy-coded data, the trailing RSTn or EOI marker is optional.
+func (s RestartScan) Compose(width, height int, intervals [][]byte) []byte {
+	size := len(s.Header) + 2*len(intervals)
+	for _, interval := range intervals {
+		size += len(interval)
+	}
+
+	buffer := make([]byte, 0, size)
+	buffer = append(buffer, s.Header...)
+	binary.BigEndian.PutUint16(buffer[s.sofOffset+5:], uint16(height))
+	binary.BigEndian.PutUint16(buffer[s.sofOffset+7:], uint16(width))
+	for i, interval := range intervals {
+		buffer = append(buffer, trimRestartMarker(interval)...)
+		if i < len(intervals)-1 {
+			buffer = append(buffer, 0xFF, 0xD0+byte(i%8))
+		}
+	}
+	return append(buffer, EOI...)
+}
+
+// trimRestartMarker removes the RSTn or EOI marker ending the entropy-coded data of an interval.
+func trimRestartMarker(interval []byte) []byte {
+	if n := len(interval); n >= 2 && interval[n-2] == 0xFF && (interval[n-1]&0xF8 == 0xD0 || interval[n-1] == 0xD9) {
+		return interval[:n-2]
+	}
+	return interval
+}
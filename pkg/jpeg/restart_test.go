@@ -0,0 +1,230 @@
+package jpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	stdjpeg "image/jpeg"
+	"testing"
+)
+
+// encodedScan is a baseline JPEG written by image/jpeg, with the offsets of its SOF and SOS segments.
+type encodedScan struct {
+	data      []byte
+	sofOffset int
+	sosOffset int
+	sosEnd    int // start of the entropy-coded data
+}
+
+func encodeScan(t *testing.T, img image.Image) encodedScan {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := stdjpeg.Encode(&buffer, img, &stdjpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+	scan := encodedScan{data: buffer.Bytes(), sofOffset: -1}
+	for offset := 2; offset+4 <= len(scan.data); {
+		size, segment := jpegSegment(scan.data[offset:])
+		switch {
+		case isSOF(segment):
+			scan.sofOffset = offset
+		case isSOS(segment):
+			scan.sosOffset, scan.sosEnd = offset, offset+size+2
+			return scan
+		}
+		offset += size + 2
+	}
+	t.Fatal("encoded JPEG without SOS marker")
+	return scan
+}
+
+// restartStrip encodes img as a single strip of width x height pixels split by restart markers,
+// each interval covering a row of the given number of 16x16 MCUs, as the strips of the NDPI files.
+// The intervals are encoded as independent JPEGs: their DC predictions start from zero as after a restart marker.
+// It returns the strip and the offsets of the intervals in it.
+func restartStrip(t *testing.T, img image.Image, width, height, interval int) ([]byte, []int) {
+	t.Helper()
+	const mcuSize = 16
+	columns := (width + mcuSize*interval - 1) / (mcuSize * interval)
+	rows := (height + mcuSize - 1) / mcuSize
+	sub := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+
+	var strip []byte
+	var starts []int
+	for i := range columns * rows {
+		col, row := i%columns, i/columns
+		x, y := col*mcuSize*interval, row*mcuSize
+		scan := encodeScan(t, sub.SubImage(image.Rect(x, y, x+mcuSize*interval, y+mcuSize)))
+		if strip == nil {
+			dri := []byte{0xFF, 0xDD, 0x00, 0x04, 0x00, byte(interval)}
+			strip = append(strip, scan.data[:scan.sosOffset]...)
+			strip = append(strip, dri...)
+			strip = append(strip, scan.data[scan.sosOffset:scan.sosEnd]...)
+			binary.BigEndian.PutUint16(strip[scan.sofOffset+5:], uint16(height))
+			binary.BigEndian.PutUint16(strip[scan.sofOffset+7:], uint16(width))
+		}
+		starts = append(starts, len(strip))
+		strip = append(strip, scan.data[scan.sosEnd:len(scan.data)-2]...)
+		if i < columns*rows-1 {
+			strip = append(strip, 0xFF, 0xD0+byte(i%8))
+		} else {
+			strip = append(strip, EOI...)
+		}
+	}
+	return strip, starts
+}
+
+// gradient is an image whose pixels all differ, padded to whole MCUs.
+func gradient(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.RGBA{R: uint8(4 * x), G: uint8(y), B: uint8(x * y), A: 0xFF})
+		}
+	}
+	return img
+}
+
+// restartMarkers returns the numbers of the RSTn markers in the entropy-coded data.
+func restartMarkers(data []byte) []int {
+	var markers []int
+	for i := 0; i+1 < len(data); i++ {
+		if data[i] == 0xFF && data[i+1]&0xF8 == 0xD0 {
+			markers = append(markers, int(data[i+1]-0xD0))
+		}
+	}
+	return markers
+}
+
+func TestParseRestartScan(t *testing.T) {
+	const width, height, interval = 56, 168, 2
+	strip, starts := restartStrip(t, gradient(64, 176), width, height, interval)
+
+	scan, err := ParseRestartScan(strip[:starts[0]])
+	if err != nil {
+		t.Fatalf("ParseRestartScan: %v", err)
+	}
+	if scan.McuWidth != 16 || scan.McuHeight != 16 || scan.RestartInterval != interval {
+		t.Errorf("ParseRestartScan: MCU %dx%d, interval %d, want 16x16, interval %d",
+			scan.McuWidth, scan.McuHeight, scan.RestartInterval, interval)
+	}
+	if len(scan.Header) != starts[0] {
+		t.Errorf("ParseRestartScan: header of %d bytes, want %d", len(scan.Header), starts[0])
+	}
+
+	plain := encodeScan(t, gradient(16, 16))
+	tests := []struct {
+		name   string
+		header []byte
+	}{
+		{"missing SOI", plain.data[2:plain.sosEnd]},
+		{"no restart interval", plain.data[:plain.sosEnd]},
+		{"missing SOS", plain.data[:plain.sosOffset]},
+		{"truncated segment", plain.data[:plain.sofOffset+6]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRestartScan(tt.header); err == nil {
+				t.Error("ParseRestartScan: no error")
+			}
+		})
+	}
+}
+
+func TestRestartScanCompose(t *testing.T) {
+	// 56x168 pixels: 4 MCU columns cut into 2 tiles, the second one 24 pixels wide, and 11 MCU rows, the last one partial
+	const width, height, interval, tileWidth = 56, 168, 2, 32
+	const columns, mcuRows = 2, 11
+	strip, starts := restartStrip(t, gradient(64, 176), width, height, interval)
+	scan, err := ParseRestartScan(strip[:starts[0]])
+	if err != nil {
+		t.Fatalf("ParseRestartScan: %v", err)
+	}
+	want, err := stdjpeg.Decode(bytes.NewReader(strip))
+	if err != nil {
+		t.Fatalf("jpeg.Decode of the strip: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		col      int
+		firstRow int
+		rows     int
+	}{
+		{"first tile", 0, 0, 2},
+		{"right edge", 1, 2, 2},
+		{"bottom right corner", 1, mcuRows - 2, 2},
+		{"more than eight intervals", 0, 1, 10},
+		{"single interval", 1, 5, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var intervals [][]byte
+			for row := tt.firstRow; row < tt.firstRow+tt.rows; row++ {
+				idx := row*columns + tt.col
+				end := len(strip)
+				if idx+1 < len(starts) {
+					end = starts[idx+1]
+				}
+				intervals = append(intervals, strip[starts[idx]:end])
+			}
+			x0, y0 := tt.col*tileWidth, tt.firstRow*scan.McuHeight
+			tileW, tileH := min(tileWidth, width-x0), min(tt.rows*scan.McuHeight, height-y0)
+
+			tile := scan.Compose(tileW, tileH, intervals)
+
+			markers := restartMarkers(tile[len(scan.Header):])
+			if len(markers) != tt.rows-1 {
+				t.Fatalf("Compose: %d restart markers, want %d", len(markers), tt.rows-1)
+			}
+			for i, marker := range markers {
+				if marker != i%8 {
+					t.Errorf("Compose: marker %d is RST%d, want RST%d", i, marker, i%8)
+				}
+			}
+			if !bytes.HasSuffix(tile, EOI) {
+				t.Error("Compose: missing EOI marker")
+			}
+
+			got, err := stdjpeg.Decode(bytes.NewReader(tile))
+			if err != nil {
+				t.Fatalf("jpeg.Decode: %v", err)
+			}
+			if size := got.Bounds().Size(); size != image.Pt(tileW, tileH) {
+				t.Fatalf("jpeg.Decode: %v pixels, want %dx%d", size, tileW, tileH)
+			}
+			for y := range tileH {
+				for x := range tileW {
+					if got.At(x, y) != want.At(x0+x, y0+y) {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, got.At(x, y), want.At(x0+x, y0+y))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTrimRestartMarker(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval []byte
+		want     []byte
+	}{
+		{"RST0", []byte{0x12, 0xFF, 0xD0}, []byte{0x12}},
+		{"RST7", []byte{0x12, 0xFF, 0xD7}, []byte{0x12}},
+		{"EOI", []byte{0x12, 0xFF, 0xD9}, []byte{0x12}},
+		{"no marker", []byte{0x12, 0x34}, []byte{0x12, 0x34}},
+		{"stuffed byte", []byte{0x12, 0xFF, 0x00}, []byte{0x12, 0xFF, 0x00}},
+		{"empty", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimRestartMarker(tt.interval); !bytes.Equal(got, tt.want) {
+				t.Errorf("trimRestartMarker(% X) = % X, want % X", tt.interval, got, tt.want)
+			}
+		})
+	}
+}
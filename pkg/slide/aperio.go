@@ -0,0 +1,125 @@
+package slide
+
+import (
+	"cmp"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"slices"
+	"strings"
+)
+
+const (
+	VendorAperio = "aperio"
+
+	aperioDescriptionPrefix = "Aperio"
+	aperioSubfileTypeLabel  = 1 // NewSubfileType of the label in files without a description
+	aperioSubfileTypeMacro  = 9 // NewSubfileType of the macro in files without a description
+)
+
+func init() {
+	RegisterFormat(aperioFormat{})
+}
+
+// aperioFormat reads the SVS files of Aperio (Leica Biosystems) scanners.
+type aperioFormat struct {
+	BaseFormat
+}
+
+func (aperioFormat) Name() string {
+	return VendorAperio
+}
+
+// Detect recognises an SVS file from the ImageDescription of its first directory, i.e.
+//
+//	Aperio Image Library v10.0.51
+//	46920x33014 [0,100 46000x32914] (256x256) JPEG/RGB Q=30|AppMag = 20|MPP = 0.4990|...
+//
+// or from its extension, some SVS files being written without a description.
+func (aperioFormat) Detect(probe Probe) int {
+	score := ScoreNone
+	if strings.HasPrefix(probe.ImageDescription, aperioDescriptionPrefix) {
+		score += ScoreDescription
+	}
+	if probe.HasExtension(".svs") {
+		score += ScoreExtension
+	}
+	return score
+}
+
+func (aperioFormat) Open(_ *SlideReader, metadata model.TIFFMetadata) (SlideMetadata, error) {
+	return partitionAperio(metadata)
+}
+
+// parseAperioDescription returns the key=value properties following the header of an Aperio ImageDescription,
+// i.e. AppMag, MPP, ScanScope ID, Date, Time, Filename...
+func parseAperioDescription(description string) map[string]string {
+	properties := make(map[string]string)
+	fields := strings.Split(description, "|")
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if key != "" {
+			properties[key] = strings.TrimSpace(value)
+		}
+	}
+	return properties
+}
+
+// partitionAperio classifies the directories of an SVS file.
+// The tiled directories form the pyramid, the second directory is the thumbnail,
+// the label and the macro are named on the second line of their ImageDescription.
+func partitionAperio(metadata model.TIFFMetadata) (SlideMetadata, error) {
+	if len(metadata) == 0 {
+		return SlideMetadata{}, errNoDirectory
+	}
+	description, _ := metadata[0].GetStringTag(tags.ImageDescription)
+	slide := SlideMetadata{
+		Vendor:     VendorAperio,
+		Properties: parseAperioDescription(description),
+	}
+	mpp := parsePositiveFloat(slide.Properties["MPP"])
+	slide.MppX, slide.MppY = mpp, mpp
+	slide.ObjectivePower = parsePositiveFloat(slide.Properties["AppMag"])
+
+	for i, directory := range metadata {
+		if _, err := directory.GetTileWidth(); err == nil {
+			slide.Directories = append(slide.Directories, directory)
+			continue
+		}
+		slide.ExtraImages = append(slide.ExtraImages, directory)
+		slide.ExtraNames = append(slide.ExtraNames, aperioAssociatedName(i, directory))
+	}
+
+	slices.SortStableFunc(slide.Directories, func(a, b model.TIFFDirectory) int {
+		widthA, _ := a.GetImageWidth()
+		widthB, _ := b.GetImageWidth()
+		return cmp.Compare(widthB, widthA)
+	})
+	return slide, nil
+}
+
+// aperioAssociatedName returns the kind of the stripped directory at index idx, empty when unknown.
+func aperioAssociatedName(idx int, directory model.TIFFDirectory) string {
+	if idx == 1 {
+		return AssociatedThumbnail
+	}
+	description, _ := directory.GetStringTag(tags.ImageDescription)
+	if _, secondLine, ok := strings.Cut(description, "\n"); ok {
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(secondLine), AssociatedLabel):
+			return AssociatedLabel
+		case strings.HasPrefix(strings.TrimSpace(secondLine), AssociatedMacro):
+			return AssociatedMacro
+		}
+	}
+	switch subfileType, _ := directory.GetIntTag(tags.NewSubfileType); subfileType {
+	case aperioSubfileTypeLabel:
+		return AssociatedLabel
+	case aperioSubfileTypeMacro:
+		return AssociatedMacro
+	}
+	return ""
+}
@@ -0,0 +1,177 @@
+package slide
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"golang.org/x/image/tiff/lzw"
+	"image"
+	"image/png"
+	"io"
+)
+
+// getDecodedTile decodes a tile which is not compressed with JPEG and encodes it as PNG,
+// to keep the 16-bit samples of fluorescence images.
+func (r *SlideReader) getDecodedTile(level model.TIFFDirectory, tileIdx int, size image.Point) ([]byte, error) {
+	tiffTileIdx, expectedWidth, expectedHeight, err := r.calculateTileWidthHeight(level, tileIdx, size)
+	if err != nil {
+		return nil, fmt.Errorf("getDecodedTile: unable to calculate expected tile size: %w", err)
+	}
+	tileWidth, _ := level.GetTileWidth()
+	tileHeight, _ := level.GetTileHeight()
+
+	data, err := r.reader.GetTileData(level, tiffTileIdx)
+	if err != nil {
+		return nil, fmt.Errorf("getDecodedTile: unable to obtain tile data: %w", err)
+	}
+	img, err := decodeRawTile(level, data, tileWidth, tileHeight, r.reader.ByteOrder())
+	if err != nil {
+		return nil, fmt.Errorf("getDecodedTile: %w", err)
+	}
+	if expectedWidth != tileWidth || expectedHeight != tileHeight {
+		img = img.(interface {
+			SubImage(r image.Rectangle) image.Image
+		}).SubImage(image.Rect(0, 0, expectedWidth, expectedHeight))
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0))
+	if err = png.Encode(buf, img); err != nil {
+		return nil, fmt.Errorf("getDecodedTile: unable to encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeRawTile decodes a tile of width x height pixels stored uncompressed, with LZW or with Deflate.
+// The samples are unsigned integers of 8 or 16 bits, grayscale or RGB, the samples of a pixel being contiguous:
+// the other layouts fail with ErrUnsupportedCompression.
+func decodeRawTile(level model.TIFFDirectory, data []byte, width, height int, byteOrder binary.ByteOrder) (image.Image, error) {
+	compression, err := level.GetCompression()
+	if err != nil {
+		compression = tags.CompressionTypeNone
+	}
+	raw, err := decompress(compression, data)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := level.GetIntTag(tags.SamplesPerPixel)
+	if err != nil {
+		samples = 1
+	}
+	bits, err := level.GetIntTag(tags.BitsPerSample)
+	if err != nil {
+		bits = 1
+	}
+	photometric, _ := level.GetPhotometricInterpretation()
+	predictor, _ := level.GetPredictor()
+	if planar, err := level.GetIntTag(tags.PlanarConfiguration); err == nil && planar != 1 && samples > 1 {
+		return nil, fmt.Errorf("%w: PlanarConfiguration %d", ErrUnsupportedCompression, planar)
+	}
+	if format, err := level.GetIntTag(tags.SampleFormat); err == nil && format != 1 {
+		return nil, fmt.Errorf("%w: SampleFormat %d", ErrUnsupportedCompression, format)
+	}
+	if bits != 8 && bits != 16 {
+		return nil, fmt.Errorf("%w: BitsPerSample %d", ErrUnsupportedCompression, bits)
+	}
+	if samples != 1 && (samples < 3 || photometric != tags.PhotometricInterpretationTypeRGB) {
+		return nil, fmt.Errorf("%w: %d samples per pixel with PhotometricInterpretation %d", ErrUnsupportedCompression, samples, photometric)
+	}
+
+	bytesPerSample := bits / 8
+	rowSize := width * samples * bytesPerSample
+	if len(raw) < rowSize*height {
+		return nil, fmt.Errorf("tile of %d bytes, %dx%d pixels expected", len(raw), width, height)
+	}
+
+	// samples as big-endian, the order of the 16-bit images of the standard library
+	if bytesPerSample == 2 && byteOrder == binary.LittleEndian {
+		for i := 0; i+1 < rowSize*height; i += 2 {
+			raw[i], raw[i+1] = raw[i+1], raw[i]
+		}
+	}
+	if predictor == tags.PredictorTypeHorizontalDifferencing {
+		undoHorizontalDifferencing(raw[:rowSize*height], rowSize, samples, bytesPerSample)
+	}
+
+	bounds := image.Rect(0, 0, width, height)
+	switch {
+	case samples == 1 && bytesPerSample == 1:
+		img := image.NewGray(bounds)
+		copy(img.Pix, raw)
+		if photometric == tags.PhotometricInterpretationTypeMinIsWhite {
+			for i := range img.Pix {
+				img.Pix[i] = ^img.Pix[i]
+			}
+		}
+		return img, nil
+	case samples == 1:
+		img := image.NewGray16(bounds)
+		copy(img.Pix, raw)
+		if photometric == tags.PhotometricInterpretationTypeMinIsWhite {
+			for i := range img.Pix {
+				img.Pix[i] = ^img.Pix[i]
+			}
+		}
+		return img, nil
+	case bytesPerSample == 1:
+		img := image.NewNRGBA(bounds)
+		for i := range width * height {
+			copy(img.Pix[4*i:4*i+3], raw[samples*i:samples*i+3])
+			img.Pix[4*i+3] = 0xFF
+		}
+		return img, nil
+	default:
+		img := image.NewNRGBA64(bounds)
+		for i := range width * height {
+			copy(img.Pix[8*i:8*i+6], raw[2*samples*i:2*samples*i+6])
+			img.Pix[8*i+6], img.Pix[8*i+7] = 0xFF, 0xFF
+		}
+		return img, nil
+	}
+}
+
+// decompress inflates the data of a tile.
+func decompress(compression tags.CompressionType, data []byte) ([]byte, error) {
+	switch compression {
+	case tags.CompressionTypeNone:
+		return bytes.Clone(data), nil
+	case tags.CompressionTypeLZW:
+		reader := lzw.NewReader(bytes.NewReader(data), lzw.MSB, 8)
+		defer reader.Close()
+		raw, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read lzw: %w", err)
+		}
+		return raw, nil
+	case tags.CompressionTypeDeflate, tags.CompressionTypeAdobeDeflate:
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read deflate: %w", err)
+		}
+		defer reader.Close()
+		raw, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read deflate: %w", err)
+		}
+		return raw, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnsupportedCompression, compression)
+}
+
+// undoHorizontalDifferencing restores the samples stored as differences with the same sample of the previous pixel,
+// 16-bit samples being big-endian.
+func undoHorizontalDifferencing(raw []byte, rowSize, samples, bytesPerSample int) {
+	stride := samples * bytesPerSample
+	for row := 0; row+rowSize <= len(raw); row += rowSize {
+		for i := row + stride; i < row+rowSize; i += bytesPerSample {
+			if bytesPerSample == 1 {
+				raw[i] += raw[i-stride]
+				continue
+			}
+			v := binary.BigEndian.Uint16(raw[i:]) + binary.BigEndian.Uint16(raw[i-stride:])
+			binary.BigEndian.PutUint16(raw[i:], v)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package slide
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+)
+
+func TestDecodeRawTile(t *testing.T) {
+	tests := []struct {
+		name        string
+		samples     uint16
+		bits        uint16
+		photometric uint16
+		planar      uint16
+		format      uint16
+		wantErr     error
+	}{
+		{"8 bits grayscale", 1, 8, 1, 1, 1, nil},
+		{"16 bits RGB", 3, 16, 2, 1, 1, nil},
+		{"separate planes", 3, 8, 2, 2, 1, ErrUnsupportedCompression},
+		{"floating point", 1, 32, 1, 1, 3, ErrUnsupportedCompression},
+		{"4 bits", 1, 4, 1, 1, 1, ErrUnsupportedCompression},
+		{"CMYK", 4, 8, 5, 1, 1, ErrUnsupportedCompression},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := func(tagID tags.TagID, v uint16) model.TIFFTag {
+				return model.DataTag[uint16]{TagID: tagID, Values: []uint16{v}}
+			}
+			level := model.NewTIFFDirectory(map[tags.TagID]model.TIFFTag{
+				tags.SamplesPerPixel:           tag(tags.SamplesPerPixel, tt.samples),
+				tags.BitsPerSample:             tag(tags.BitsPerSample, tt.bits),
+				tags.PhotometricInterpretation: tag(tags.PhotometricInterpretation, tt.photometric),
+				tags.PlanarConfiguration:       tag(tags.PlanarConfiguration, tt.planar),
+				tags.SampleFormat:              tag(tags.SampleFormat, tt.format),
+			})
+			data := make([]byte, 2*2*int(tt.samples)*int(tt.bits)/8)
+
+			img, err := decodeRawTile(level, data, 2, 2, binary.LittleEndian)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodeRawTile: %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && img.Bounds().Dx() != 2 {
+				t.Errorf("decodeRawTile: %d pixels wide, want 2", img.Bounds().Dx())
+			}
+		})
+	}
+}
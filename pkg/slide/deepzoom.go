@@ -0,0 +1,159 @@
+package slide
+
+import (
+	"encoding/xml"
+	"fmt"
+	xdraw "golang.org/x/image/draw"
+	"image"
+	"math"
+)
+
+const (
+	DeepZoomTileSize = 254 // tile size of the Deep Zoom pyramids, 256 pixels with an overlap of 1
+	DeepZoomOverlap  = 1
+
+	deepZoomNamespace = "http://schemas.microsoft.com/deepzoom/2008"
+
+	// deepZoomMaxReadPixels limits the region of the native level read for a tile, before it is scaled down:
+	// the tiles of the small Deep Zoom levels of a slide without reduced levels would read the level 0 whole.
+	deepZoomMaxReadPixels = 4096 * 4096
+)
+
+// DeepZoom exposes a slide as a Deep Zoom pyramid, as expected by OpenSeadragon: the level 0 is a single pixel,
+// each level doubles the dimensions of the previous one up to the level 0 of the slide.
+// The tiles of a Deep Zoom level are read from the native level of the closest downsample, then scaled.
+type DeepZoom struct {
+	reader     *SlideReader
+	pyramid    PyramidMetadata
+	tileSize   int
+	overlap    int
+	dimensions []image.Point // dimensions of the Deep Zoom levels, from the smallest to the largest
+
+	maxReadPixels int
+}
+
+// deepZoomImage is the DZI descriptor of a Deep Zoom pyramid.
+type deepZoomImage struct {
+	XMLName  xml.Name `xml:"Image"`
+	XMLNS    string   `xml:"xmlns,attr"`
+	Format   string   `xml:"Format,attr"`
+	Overlap  int      `xml:"Overlap,attr"`
+	TileSize int      `xml:"TileSize,attr"`
+	Size     struct {
+		Width  int `xml:"Width,attr"`
+		Height int `xml:"Height,attr"`
+	} `xml:"Size"`
+}
+
+// NewDeepZoom creates the Deep Zoom pyramid of the default pyramid of an opened slide.
+func NewDeepZoom(reader *SlideReader, tileSize, overlap int) (*DeepZoom, error) {
+	if tileSize <= 0 || overlap < 0 {
+		return nil, fmt.Errorf("invalid Deep Zoom tile size %d with overlap %d", tileSize, overlap)
+	}
+	pyramid, err := reader.GetMetadata()
+	if err != nil {
+		return nil, err
+	}
+	if len(pyramid.Levels) == 0 {
+		return nil, fmt.Errorf("slide without levels")
+	}
+
+	size := image.Pt(pyramid.Levels[0].ImageWidth, pyramid.Levels[0].ImageHeight)
+	if size.X <= 0 || size.Y <= 0 {
+		return nil, fmt.Errorf("empty slide of %dx%d pixels", size.X, size.Y)
+	}
+	dimensions := []image.Point{size}
+	for size.X > 1 || size.Y > 1 {
+		size = image.Pt(max(1, ceilDiv(size.X, 2)), max(1, ceilDiv(size.Y, 2)))
+		dimensions = append(dimensions, size)
+	}
+	for i, j := 0, len(dimensions)-1; i < j; i, j = i+1, j-1 {
+		dimensions[i], dimensions[j] = dimensions[j], dimensions[i]
+	}
+
+	return &DeepZoom{
+		reader:     reader,
+		pyramid:    pyramid,
+		tileSize:   tileSize,
+		overlap:    overlap,
+		dimensions: dimensions,
+
+		maxReadPixels: deepZoomMaxReadPixels,
+	}, nil
+}
+
+// LevelCount returns the number of Deep Zoom levels.
+func (z *DeepZoom) LevelCount() int {
+	return len(z.dimensions)
+}
+
+// TileCount returns the number of columns and rows of tiles of a Deep Zoom level.
+func (z *DeepZoom) TileCount(level int) (int, int) {
+	if level < 0 || level >= len(z.dimensions) {
+		return 0, 0
+	}
+	return ceilDiv(z.dimensions[level].X, z.tileSize), ceilDiv(z.dimensions[level].Y, z.tileSize)
+}
+
+// Descriptor returns the DZI XML describing the pyramid, its tiles being encoded with format (jpeg or png).
+func (z *DeepZoom) Descriptor(format string) ([]byte, error) {
+	descriptor := deepZoomImage{
+		XMLNS:    deepZoomNamespace,
+		Format:   format,
+		Overlap:  z.overlap,
+		TileSize: z.tileSize,
+	}
+	descriptor.Size.Width = z.dimensions[len(z.dimensions)-1].X
+	descriptor.Size.Height = z.dimensions[len(z.dimensions)-1].Y
+	data, err := xml.Marshal(descriptor)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode DZI: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// Tile returns the tile at column col and row row of a Deep Zoom level, overlapping its neighbours by the overlap.
+func (z *DeepZoom) Tile(level, col, row int) (image.Image, error) {
+	columns, rows := z.TileCount(level)
+	if level < 0 || level >= len(z.dimensions) {
+		return nil, fmt.Errorf("Deep Zoom %w: %d", ErrLevelOutOfRange, level)
+	}
+	if col < 0 || col >= columns || row < 0 || row >= rows {
+		return nil, fmt.Errorf("Deep Zoom %w: %d_%d", ErrTileOutOfRange, col, row)
+	}
+
+	// bounds of the tile in the Deep Zoom level, overlaps included
+	dimensions := z.dimensions[level]
+	bounds := image.Rect(col*z.tileSize-z.overlap, row*z.tileSize-z.overlap, (col+1)*z.tileSize+z.overlap, (row+1)*z.tileSize+z.overlap).
+		Intersect(image.Rect(0, 0, dimensions.X, dimensions.Y))
+
+	// native level of the largest downsample not exceeding the one of the Deep Zoom level
+	level0 := z.pyramid.Levels[0]
+	downsampleX := float64(level0.ImageWidth) / float64(dimensions.X)
+	downsampleY := float64(level0.ImageHeight) / float64(dimensions.Y)
+	slideLevel := z.pyramid.BestLevel(min(downsampleX, downsampleY))
+	native := z.pyramid.Levels[slideLevel]
+	scaleX := float64(native.ImageWidth) / float64(level0.ImageWidth)
+	scaleY := float64(native.ImageHeight) / float64(level0.ImageHeight)
+
+	// region of the native level covered by the tile
+	x0, y0 := int(float64(bounds.Min.X)*downsampleX), int(float64(bounds.Min.Y)*downsampleY)
+	nativeX, nativeY := int(float64(x0)*scaleX), int(float64(y0)*scaleY)
+	width := min(native.ImageWidth-nativeX, int(math.Ceil(float64(bounds.Dx())*downsampleX*scaleX)))
+	height := min(native.ImageHeight-nativeY, int(math.Ceil(float64(bounds.Dy())*downsampleY*scaleY)))
+	if width*height > z.maxReadPixels {
+		return nil, fmt.Errorf("Deep Zoom tile %d_%d of level %d: %w: %dx%d pixels of level %d to read",
+			col, row, level, ErrRegionTooLarge, width, height, slideLevel)
+	}
+	region, err := z.reader.ReadRegion(slideLevel, x0, y0, max(1, width), max(1, height))
+	if err != nil {
+		return nil, err
+	}
+	if region.Bounds().Size() == bounds.Size() {
+		return region, nil
+	}
+
+	tile := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	xdraw.BiLinear.Scale(tile, tile.Bounds(), region, region.Bounds(), xdraw.Src, nil)
+	return tile, nil
+}
@@ -0,0 +1,124 @@
+package slide
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image"
+	"testing"
+)
+
+// openDeepZoom opens the Deep Zoom pyramid of a single level slide of 192x128 pixels, in tiles of 64 pixels.
+func openDeepZoom(t *testing.T) *DeepZoom {
+	t.Helper()
+	reader := NewSlideReader()
+	if err := reader.OpenFile(writeTiledJPEGTIFF(t, 3, 2, 64)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reader.Close() })
+	zoom, err := NewDeepZoom(reader, 64, 1)
+	if err != nil {
+		t.Fatalf("NewDeepZoom: %v", err)
+	}
+	return zoom
+}
+
+func TestDeepZoomLevels(t *testing.T) {
+	zoom := openDeepZoom(t)
+	want := []image.Point{{1, 1}, {2, 1}, {3, 2}, {6, 4}, {12, 8}, {24, 16}, {48, 32}, {96, 64}, {192, 128}}
+	if zoom.LevelCount() != len(want) {
+		t.Fatalf("LevelCount() = %d, want %d", zoom.LevelCount(), len(want))
+	}
+	for level, dimensions := range want {
+		if zoom.dimensions[level] != dimensions {
+			t.Errorf("level %d of %v pixels, want %v", level, zoom.dimensions[level], dimensions)
+		}
+	}
+
+	tests := []struct {
+		level         int
+		columns, rows int
+	}{
+		{0, 1, 1},
+		{6, 1, 1},
+		{7, 2, 1},
+		{8, 3, 2},
+		{9, 0, 0},
+		{-1, 0, 0},
+	}
+	for _, tt := range tests {
+		if columns, rows := zoom.TileCount(tt.level); columns != tt.columns || rows != tt.rows {
+			t.Errorf("TileCount(%d) = %d, %d, want %d, %d", tt.level, columns, rows, tt.columns, tt.rows)
+		}
+	}
+}
+
+func TestDeepZoomTile(t *testing.T) {
+	zoom := openDeepZoom(t)
+	tests := []struct {
+		name     string
+		level    int
+		col, row int
+		want     image.Point // size of the tile, overlaps included
+		wantErr  error
+	}{
+		{name: "top left corner", level: 8, want: image.Pt(65, 65)},
+		{name: "overlapping on the left and on the right", level: 8, col: 1, want: image.Pt(66, 65)},
+		{name: "bottom right corner", level: 8, col: 2, row: 1, want: image.Pt(65, 65)},
+		{name: "right edge", level: 7, col: 1, want: image.Pt(33, 64)},
+		{name: "single pixel level", level: 0, want: image.Pt(1, 1)},
+		{name: "scaled level", level: 6, want: image.Pt(48, 32)},
+		{name: "level out of range", level: 9, wantErr: ErrLevelOutOfRange},
+		{name: "column out of range", level: 8, col: 3, wantErr: ErrTileOutOfRange},
+		{name: "negative row", level: 8, row: -1, wantErr: ErrTileOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tile, err := zoom.Tile(tt.level, tt.col, tt.row)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Tile: %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && tile.Bounds().Size() != tt.want {
+				t.Errorf("Tile: %v pixels, want %v", tile.Bounds().Size(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDeepZoomTileReadLimit(t *testing.T) {
+	zoom := openDeepZoom(t)
+	zoom.maxReadPixels = 66 * 65 // a tile of the level 0 of the slide, overlaps included
+
+	if _, err := zoom.Tile(8, 1, 0); err != nil {
+		t.Errorf("Tile of the level 0 of the slide: %v", err)
+	}
+	// the single pixel of the smallest level is scaled from the whole slide
+	if _, err := zoom.Tile(0, 0, 0); !errors.Is(err, ErrRegionTooLarge) {
+		t.Errorf("Tile of the smallest level: %v, want %v", err, ErrRegionTooLarge)
+	}
+}
+
+func TestDeepZoomDescriptor(t *testing.T) {
+	zoom := openDeepZoom(t)
+	data, err := zoom.Descriptor("jpeg")
+	if err != nil {
+		t.Fatalf("Descriptor: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte(xml.Header)) {
+		t.Errorf("Descriptor without XML header: %s", data)
+	}
+
+	var got deepZoomImage
+	if err := xml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("xml.Unmarshal: %v", err)
+	}
+	if got.XMLName.Space != deepZoomNamespace || got.XMLName.Local != "Image" {
+		t.Errorf("root element %v, want Image of %s", got.XMLName, deepZoomNamespace)
+	}
+	if got.Format != "jpeg" || got.TileSize != 64 || got.Overlap != 1 {
+		t.Errorf("Format %q, TileSize %d, Overlap %d, want jpeg, 64, 1", got.Format, got.TileSize, got.Overlap)
+	}
+	if got.Size.Width != 192 || got.Size.Height != 128 {
+		t.Errorf("Size %dx%d, want 192x128", got.Size.Width, got.Size.Height)
+	}
+}
@@ -0,0 +1,6 @@
+// Package slide reads pyramidal whole-slide images stored as TIFF files.
+//
+// A SlideReader exposes the levels of the pyramid (see PyramidMetadata) and serves their tiles as JPEG.
+//
+// The exported API of this package follows semantic versioning.
+package slide
@@ -0,0 +1,175 @@
+package slide
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Detection scores of the formats, added up by Format.Detect.
+const (
+	ScoreNone        = 0   // not a file of the format
+	ScoreGeneric     = 1   // any TIFF file
+	ScoreExtension   = 10  // file extension of the format
+	ScoreTag         = 50  // Make or Software written by the vendor
+	ScoreDescription = 100 // signature of the vendor in the ImageDescription or in its private tags
+)
+
+// Format is a driver of a slide format. The drivers register themselves with RegisterFormat,
+// a slide is opened by the driver detecting it with the highest score, see formatPrecedence for the ties.
+//
+// A driver is stateless: the layout of an opened slide is held by the SlideMetadata returned by Open,
+// which is given back to the other methods. BaseFormat implements the methods common to the TIFF pyramids.
+type Format interface {
+	// Name is the vendor of the format, i.e. aperio.
+	Name() string
+	// Detect scores how likely the probed file is of the format, ScoreNone when it is not.
+	Detect(probe Probe) int
+	// Open classifies the directories of the slide.
+	Open(r *SlideReader, metadata model.TIFFMetadata) (SlideMetadata, error)
+	// Levels returns the directories of the pyramid, from the largest to the smallest.
+	Levels(slide SlideMetadata) model.TIFFMetadata
+	// AssociatedImages returns the kinds of the associated images, i.e. thumbnail, label, macro.
+	AssociatedImages(slide SlideMetadata) []string
+	// Properties returns the properties parsed from the vendor description.
+	Properties(slide SlideMetadata) map[string]string
+	// ReadTile returns an encoded tile of a level of the pyramid.
+	ReadTile(r *SlideReader, slide SlideMetadata, levelIdx, tileIdx int) ([]byte, error)
+}
+
+// Probe is what the formats are detected from: the name of the file and the tags of its directories.
+type Probe struct {
+	Name             string             // base name of the file, lower case
+	Make             string             // tags of the first directory
+	Software         string             //
+	ImageDescription string             //
+	Metadata         model.TIFFMetadata // all the directories
+}
+
+// NewProbe collects the tags of the first directory, name being a path, an S3 key or a URL.
+func NewProbe(name string, metadata model.TIFFMetadata) Probe {
+	name, _, _ = strings.Cut(name, "?")
+	probe := Probe{
+		Name:     strings.ToLower(path.Base(name)),
+		Metadata: metadata,
+	}
+	if len(metadata) > 0 {
+		probe.Make, _ = metadata[0].GetStringTag(tags.Make)
+		probe.Software, _ = metadata[0].GetStringTag(tags.Software)
+		probe.ImageDescription, _ = metadata[0].GetStringTag(tags.ImageDescription)
+	}
+	return probe
+}
+
+// HasExtension tells if the name of the file ends with one of the extensions, i.e. ".svs" or ".ome.tif".
+func (p Probe) HasExtension(extensions ...string) bool {
+	return slices.ContainsFunc(extensions, func(extension string) bool {
+		return strings.HasSuffix(p.Name, extension)
+	})
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   []Format
+)
+
+// formatPrecedence breaks the ties between the detection scores, the formats not listed coming next
+// in the order of their registration.
+var formatPrecedence = []string{VendorHamamatsu, VendorAperio, VendorVentana, VendorLeica, VendorPhilips, VendorOME}
+
+// RegisterFormat makes a format available to the readers, it panics when a format of the same name is registered.
+func RegisterFormat(format Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	if slices.ContainsFunc(formats, func(f Format) bool { return f.Name() == format.Name() }) {
+		panic(fmt.Sprintf("slide: format %s registered twice", format.Name()))
+	}
+	formats = append(formats, format)
+}
+
+// Formats returns the names of the registered formats.
+func Formats() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	names := make([]string, 0, len(formats))
+	for _, format := range formats {
+		names = append(names, format.Name())
+	}
+	return names
+}
+
+// detectFormats returns the formats detecting the probed file, from the highest score to the lowest.
+func detectFormats(probe Probe) []Format {
+	type candidate struct {
+		format Format
+		score  int
+	}
+	formatsMu.RLock()
+	var candidates []candidate
+	for _, format := range formats {
+		if score := format.Detect(probe); score > ScoreNone {
+			candidates = append(candidates, candidate{format, score})
+		}
+	}
+	formatsMu.RUnlock()
+
+	rank := func(format Format) int {
+		if idx := slices.Index(formatPrecedence, format.Name()); idx >= 0 {
+			return idx
+		}
+		return len(formatPrecedence)
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), cmp.Compare(rank(a.format), rank(b.format)))
+	})
+	detected := make([]Format, 0, len(candidates))
+	for _, c := range candidates {
+		detected = append(detected, c.format)
+	}
+	return detected
+}
+
+// errNoDirectory is returned by the formats reading the first directory of a file whose IFD chain is empty.
+var errNoDirectory = errors.New("no TIFF directory")
+
+// openFormat opens the slide with the formats detecting it, falling back to the next one when a format fails.
+func (r *SlideReader) openFormat(probe Probe, metadata model.TIFFMetadata) (Format, SlideMetadata, error) {
+	for _, format := range detectFormats(probe) {
+		slide, err := format.Open(r, metadata)
+		if err != nil {
+			slog.Warn("Unable to open slide, trying the next format", "format", format.Name(), "error", err)
+			continue
+		}
+		slide.Vendor = format.Name()
+		slog.Debug("Slide format detected", "name", probe.Name, "format", format.Name())
+		return format, slide, nil
+	}
+	return nil, SlideMetadata{}, errors.New("no format able to open the slide")
+}
+
+// BaseFormat implements the methods of Format common to the TIFF pyramids, to be embedded by the drivers.
+type BaseFormat struct{}
+
+func (BaseFormat) Levels(slide SlideMetadata) model.TIFFMetadata {
+	return slide.Directories
+}
+
+func (BaseFormat) AssociatedImages(slide SlideMetadata) []string {
+	return slide.AssociatedNames()
+}
+
+func (BaseFormat) Properties(slide SlideMetadata) map[string]string {
+	return slide.Properties
+}
+
+// ReadTile serves the JPEG tiles as stored, decodes the other ones and recomposes the stripped levels.
+func (BaseFormat) ReadTile(r *SlideReader, slide SlideMetadata, levelIdx, tileIdx int) ([]byte, error) {
+	return r.getTile(slide, levelIdx, tileIdx)
+}
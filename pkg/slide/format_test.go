@@ -0,0 +1,151 @@
+package slide
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+)
+
+// scoredFormat detects every file with a fixed score.
+type scoredFormat struct {
+	BaseFormat
+	name  string
+	score int
+}
+
+func (f scoredFormat) Name() string { return f.name }
+
+func (f scoredFormat) Detect(Probe) int { return f.score }
+
+func (f scoredFormat) Open(*SlideReader, model.TIFFMetadata) (SlideMetadata, error) {
+	return SlideMetadata{}, nil
+}
+
+// reversedFormat serves its pyramid from the smallest level.
+type reversedFormat struct {
+	scoredFormat
+}
+
+func (reversedFormat) Levels(slide SlideMetadata) model.TIFFMetadata {
+	return slices.Concat(slide.Directories[1:], slide.Directories[:1])
+}
+
+func TestPropertiesFromFormatLevels(t *testing.T) {
+	directory := func(value string) model.TIFFDirectory {
+		return model.NewTIFFDirectory(map[tags.TagID]model.TIFFTag{
+			tags.Make: model.DataTag[string]{TagID: tags.Make, Values: []string{value}},
+		})
+	}
+	pyramid := SlideMetadata{Directories: model.TIFFMetadata{directory("first"), directory("second")}}
+
+	tests := []struct {
+		name   string
+		format Format
+		want   string
+	}{
+		{"base format", scoredFormat{name: VendorGeneric}, "first"},
+		{"levels of the format", reversedFormat{scoredFormat{name: VendorGeneric}}, "second"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &SlideReader{format: tt.format, pyramid: pyramid}
+			if got := r.Properties()["tiff.Make"]; got != tt.want {
+				t.Errorf("Properties()[tiff.Make] = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectFormatsOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		registered []scoredFormat // in the order of registration
+		want       []string
+	}{
+		{
+			name: "highest score first",
+			registered: []scoredFormat{
+				{name: VendorGeneric, score: ScoreGeneric},
+				{name: VendorPhilips, score: ScoreTag},
+				{name: VendorAperio, score: ScoreDescription},
+			},
+			want: []string{VendorAperio, VendorPhilips, VendorGeneric},
+		},
+		{
+			name: "ties in the order of precedence",
+			registered: []scoredFormat{
+				{name: VendorOME, score: ScoreExtension},
+				{name: VendorPhilips, score: ScoreExtension},
+				{name: VendorLeica, score: ScoreExtension},
+				{name: VendorVentana, score: ScoreExtension},
+				{name: VendorAperio, score: ScoreExtension},
+				{name: VendorHamamatsu, score: ScoreExtension},
+			},
+			want: []string{VendorHamamatsu, VendorAperio, VendorVentana, VendorLeica, VendorPhilips, VendorOME},
+		},
+		{
+			name: "unlisted formats after the listed ones, in the order of registration",
+			registered: []scoredFormat{
+				{name: "zeiss", score: ScoreExtension},
+				{name: VendorGeneric, score: ScoreExtension},
+				{name: VendorOME, score: ScoreExtension},
+				{name: "mirax", score: ScoreExtension},
+			},
+			want: []string{VendorOME, "zeiss", VendorGeneric, "mirax"},
+		},
+		{
+			name: "not detected",
+			registered: []scoredFormat{
+				{name: VendorAperio, score: ScoreNone},
+				{name: VendorGeneric, score: ScoreGeneric},
+			},
+			want: []string{VendorGeneric},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatsMu.Lock()
+			registered := formats
+			formats = nil
+			for _, format := range tt.registered {
+				formats = append(formats, format)
+			}
+			formatsMu.Unlock()
+			defer func() {
+				formatsMu.Lock()
+				formats = registered
+				formatsMu.Unlock()
+			}()
+
+			var got []string
+			for _, format := range detectFormats(Probe{}) {
+				got = append(got, format.Name())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("detectFormats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionEmptyMetadata(t *testing.T) {
+	tests := []struct {
+		name      string
+		partition func(model.TIFFMetadata) (SlideMetadata, error)
+	}{
+		{name: VendorAperio, partition: partitionAperio},
+		{name: VendorPhilips, partition: partitionPhilips},
+		{name: VendorLeica, partition: partitionLeica},
+		{name: VendorOME, partition: partitionOME},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.partition(nil); !errors.Is(err, errNoDirectory) {
+				t.Errorf("partition(nil) error = %v, want %v", err, errNoDirectory)
+			}
+		})
+	}
+}
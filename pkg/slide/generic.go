@@ -0,0 +1,95 @@
+package slide
+
+import (
+	"cmp"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"slices"
+)
+
+// VendorGeneric is the format of the TIFF files of no known vendor.
+const VendorGeneric = "generic"
+
+func init() {
+	RegisterFormat(genericFormat{})
+}
+
+// genericFormat reads any TIFF file, the largest pyramid of its directories being the slide.
+type genericFormat struct {
+	BaseFormat
+}
+
+func (genericFormat) Name() string {
+	return VendorGeneric
+}
+
+func (genericFormat) Detect(Probe) int {
+	return ScoreGeneric
+}
+
+func (genericFormat) Open(_ *SlideReader, metadata model.TIFFMetadata) (SlideMetadata, error) {
+	return partitionGeneric(metadata), nil
+}
+
+// partitionGeneric extracts the main pyramid from the extra images.
+func partitionGeneric(metadata model.TIFFMetadata) SlideMetadata {
+	// pyramid stored in SubIFDs (OME-TIFF, libvips --subifd): the reduced resolutions are children of the full one
+	for i, directory := range metadata {
+		if levels := subIFDPyramid(directory); len(levels) > 1 {
+			var extra model.TIFFMetadata
+			extra = append(extra, metadata[:i]...)
+			extra = append(extra, metadata[i+1:]...)
+			return SlideMetadata{
+				Directories: levels,
+				ExtraImages: extra,
+			}
+		}
+	}
+
+	// partition the directories.
+	// extract the main pyramid from extra stripped images.
+	m := make(map[string]model.TIFFMetadata)
+	for _, directory := range metadata {
+		pyramidID := directory.GetPyramidID()
+		m[pyramidID] = append(m[pyramidID], directory)
+	}
+
+	// extract the largest pyramid of images
+	var largestPyramidKey string
+	var extra model.TIFFMetadata
+	for k, directories := range m {
+		if len(m[largestPyramidKey]) < len(directories) {
+			largestPyramidKey = k
+		}
+	}
+
+	// flatten the rest of images
+	for k, directories := range m {
+		if k != largestPyramidKey {
+			extra = append(extra, directories...)
+		}
+	}
+
+	return SlideMetadata{
+		Directories: m[largestPyramidKey],
+		ExtraImages: extra,
+	}
+}
+
+// subIFDPyramid returns the tiled directory followed by its tiled SubIFDs, from the largest to the smallest.
+func subIFDPyramid(directory model.TIFFDirectory) model.TIFFMetadata {
+	if _, err := directory.GetTileWidth(); err != nil {
+		return nil
+	}
+	levels := model.TIFFMetadata{directory}
+	for _, subIFD := range directory.SubIFDs() {
+		if _, err := subIFD.GetTileWidth(); err == nil {
+			levels = append(levels, subIFD)
+		}
+	}
+	slices.SortStableFunc(levels, func(a, b model.TIFFDirectory) int {
+		widthA, _ := a.GetImageWidth()
+		widthB, _ := b.GetImageWidth()
+		return cmp.Compare(widthB, widthA)
+	})
+	return levels
+}
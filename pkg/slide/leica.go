@@ -0,0 +1,202 @@
+package slide
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"image"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	VendorLeica = "leica"
+
+	leicaNamespace = "leica-microsystems.com/scn"
+)
+
+// leicaSCN is the XML ImageDescription of the first directory of a Leica SCN file, i.e.
+//
+//	<scn xmlns="http://www.leica-microsystems.com/scn/2010/10/01">
+//	  <collection name="..." uuid="..." sizeX="76000000" sizeY="26000000">
+//	    <barcode>...</barcode>
+//	    <image name="..." uuid="...">
+//	      <device model="Leica SCN400;Leica SCN" version="..."/>
+//	      <pixels sizeX="4668" sizeY="1616">
+//	        <dimension sizeX="4668" sizeY="1616" r="0" ifd="1"/>
+//	        ...
+//	      </pixels>
+//	      <view sizeX="76000000" sizeY="26000000" offsetX="0" offsetY="0"/>
+//	      ...
+//	    </image>
+//	  </collection>
+//	</scn>
+type leicaSCN struct {
+	Collection struct {
+		Name    string       `xml:"name,attr"`
+		UUID    string       `xml:"uuid,attr"`
+		SizeX   int64        `xml:"sizeX,attr"`
+		SizeY   int64        `xml:"sizeY,attr"`
+		Barcode string       `xml:"barcode"`
+		Images  []leicaImage `xml:"image"`
+	} `xml:"collection"`
+}
+
+type leicaImage struct {
+	Name         string `xml:"name,attr"`
+	UUID         string `xml:"uuid,attr"`
+	CreationDate string `xml:"creationDate"`
+	Device       struct {
+		Model   string `xml:"model,attr"`
+		Version string `xml:"version,attr"`
+	} `xml:"device"`
+	Dimensions []leicaDimension `xml:"pixels>dimension"`
+	View       struct {
+		SizeX   int64 `xml:"sizeX,attr"`
+		SizeY   int64 `xml:"sizeY,attr"`
+		OffsetX int64 `xml:"offsetX,attr"`
+		OffsetY int64 `xml:"offsetY,attr"`
+	} `xml:"view"`
+	Objective          string `xml:"scanSettings>objectiveSettings>objective"`
+	NumericalAperture  string `xml:"scanSettings>illuminationSettings>numericalAperture"`
+	IlluminationSource string `xml:"scanSettings>illuminationSettings>illuminationSource"`
+}
+
+// leicaDimension locates a level of an image: r is the resolution index, c the channel and z the plane.
+type leicaDimension struct {
+	SizeX int `xml:"sizeX,attr"`
+	SizeY int `xml:"sizeY,attr"`
+	R     int `xml:"r,attr"`
+	C     int `xml:"c,attr"`
+	Z     int `xml:"z,attr"`
+	IFD   int `xml:"ifd,attr"`
+}
+
+// leicaCalibrate derives the resolution of the main image from its size on the glass slide,
+// and its bounds in the pixels of its level 0 from its offset in the collection.
+func leicaCalibrate(slide *SlideMetadata, slideImage SlideImage) {
+	width, err := slideImage.Directories[0].GetImageWidth()
+	if err != nil || width == 0 || slideImage.Width <= 0 {
+		return
+	}
+	height, err := slideImage.Directories[0].GetImageHeight()
+	if err != nil || height == 0 || slideImage.Height <= 0 {
+		return
+	}
+	nmX, nmY := float64(slideImage.Width)/float64(width), float64(slideImage.Height)/float64(height)
+	slide.MppX, slide.MppY = nmX/1000, nmY/1000
+	x, y := int(math.Round(float64(slideImage.OffsetX)/nmX)), int(math.Round(float64(slideImage.OffsetY)/nmY))
+	slide.Bounds = image.Rect(x, y, x+width, y+height)
+}
+
+// isMacro tells if the image covers the whole collection, i.e. the overview of the glass slide.
+func (i leicaImage) isMacro(sizeX, sizeY int64) bool {
+	return i.View.OffsetX == 0 && i.View.OffsetY == 0 && i.View.SizeX == sizeX && i.View.SizeY == sizeY
+}
+
+func init() {
+	RegisterFormat(leicaFormat{})
+}
+
+// leicaFormat reads the SCN collections of the Leica scanners.
+type leicaFormat struct {
+	BaseFormat
+}
+
+func (leicaFormat) Name() string {
+	return VendorLeica
+}
+
+// Detect recognises a Leica SCN file from the XML ImageDescription of its first directory.
+func (leicaFormat) Detect(probe Probe) int {
+	score := ScoreNone
+	if strings.HasPrefix(probe.ImageDescription, "<?xml") && strings.Contains(probe.ImageDescription, leicaNamespace) {
+		score += ScoreDescription
+	}
+	if probe.HasExtension(".scn") {
+		score += ScoreExtension
+	}
+	return score
+}
+
+func (leicaFormat) Open(_ *SlideReader, metadata model.TIFFMetadata) (SlideMetadata, error) {
+	return partitionLeica(metadata)
+}
+
+// partitionLeica splits the directories of a Leica SCN file into the images of the collection.
+// Each image has its own pyramid, the first main image is the default pyramid and the macro is an associated image.
+func partitionLeica(metadata model.TIFFMetadata) (SlideMetadata, error) {
+	if len(metadata) == 0 {
+		return SlideMetadata{}, errNoDirectory
+	}
+	description, _ := metadata[0].GetStringTag(tags.ImageDescription)
+	var scn leicaSCN
+	if err := xml.Unmarshal([]byte(description), &scn); err != nil {
+		return SlideMetadata{}, fmt.Errorf("unable to parse Leica ImageDescription: %w", err)
+	}
+	collection := scn.Collection
+
+	slide := SlideMetadata{
+		Vendor: VendorLeica,
+		Properties: map[string]string{
+			"collection.name": collection.Name,
+			"collection.uuid": collection.UUID,
+			"barcode":         collection.Barcode,
+		},
+	}
+
+	for _, leicaImage := range collection.Images {
+		slideImage := SlideImage{
+			Name:    leicaImage.Name,
+			UUID:    leicaImage.UUID,
+			Macro:   leicaImage.isMacro(collection.SizeX, collection.SizeY),
+			OffsetX: leicaImage.View.OffsetX,
+			OffsetY: leicaImage.View.OffsetY,
+			Width:   leicaImage.View.SizeX,
+			Height:  leicaImage.View.SizeY,
+		}
+
+		// the first channel and plane of fluorescence images
+		dimensions := slices.Clone(leicaImage.Dimensions)
+		slices.SortStableFunc(dimensions, func(a, b leicaDimension) int { return cmp.Compare(a.R, b.R) })
+		for _, dimension := range dimensions {
+			if dimension.C != 0 || dimension.Z != 0 {
+				continue
+			}
+			if dimension.IFD < 0 || dimension.IFD >= len(metadata) {
+				return SlideMetadata{}, fmt.Errorf("leica image %s references a missing IFD: %d", leicaImage.UUID, dimension.IFD)
+			}
+			slideImage.Directories = append(slideImage.Directories, metadata[dimension.IFD])
+		}
+		if len(slideImage.Directories) == 0 {
+			slog.Warn("Leica image without pixels, ignored", "uuid", leicaImage.UUID)
+			continue
+		}
+		slide.Images = append(slide.Images, slideImage)
+
+		if slideImage.Macro {
+			slide.ExtraImages = append(slide.ExtraImages, slideImage.Directories[0])
+			slide.ExtraNames = append(slide.ExtraNames, AssociatedMacro)
+			continue
+		}
+		if slide.Directories == nil {
+			slide.Directories = slideImage.Directories
+			slide.Properties["creationDate"] = leicaImage.CreationDate
+			slide.Properties["device.model"] = leicaImage.Device.Model
+			slide.Properties["device.version"] = leicaImage.Device.Version
+			slide.Properties["objective"] = leicaImage.Objective
+			slide.Properties["numericalAperture"] = leicaImage.NumericalAperture
+			slide.Properties["illuminationSource"] = leicaImage.IlluminationSource
+			slide.ObjectivePower = parsePositiveFloat(leicaImage.Objective)
+			leicaCalibrate(&slide, slideImage)
+		}
+	}
+	if slide.Directories == nil {
+		return SlideMetadata{}, fmt.Errorf("leica collection %s has no main image", collection.UUID)
+	}
+	return slide, nil
+}
@@ -0,0 +1,282 @@
+package slide
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	jpegio "github.com/chennequin/fast-tiff-reader/pkg/jpeg"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	VendorHamamatsu = "hamamatsu"
+
+	ndpiMake          = "Hamamatsu"
+	ndpiMacroLens     = -1   // NDPISourceLens of the macro image
+	ndpiMaxTileHeight = 1024 // virtual tiles are square, up to this height
+)
+
+// RestartGrid cuts the single JPEG strip of a NDPI level into virtual tiles, along its restart intervals.
+// A restart interval covers TileWidth pixels of a row of MCUs: a virtual tile is made of the intervals
+// of RowsPerTile consecutive rows of MCUs, assembled without decoding them.
+type RestartGrid struct {
+	Scan        jpegio.RestartScan // header of the strip
+	McuStarts   []uint64           // offset of each restart interval in the strip
+	StripSize   uint64             // size of the strip, ending the last interval
+	Columns     int                // restart intervals of a row of MCUs
+	McuRows     int                // rows of MCUs of the level
+	RowsPerTile int                // rows of MCUs of a virtual tile
+	TileWidth   int                // size of the virtual tiles
+	TileHeight  int                //
+	Width       int                // size of the level
+	Height      int                //
+}
+
+func init() {
+	RegisterFormat(hamamatsuFormat{})
+}
+
+// hamamatsuFormat reads the NDPI files of the Hamamatsu NanoZoomer scanners.
+type hamamatsuFormat struct {
+	BaseFormat
+}
+
+func (hamamatsuFormat) Name() string {
+	return VendorHamamatsu
+}
+
+// Detect recognises a Hamamatsu NDPI file from the NDPIFormatFlag tag of its directories.
+func (hamamatsuFormat) Detect(probe Probe) int {
+	score := ScoreNone
+	for _, directory := range probe.Metadata {
+		if _, err := directory.Tag(tags.NDPIFormatFlag); err == nil {
+			score += ScoreDescription
+			break
+		}
+	}
+	if strings.HasPrefix(probe.Make, ndpiMake) {
+		score += ScoreTag
+	}
+	if probe.HasExtension(".ndpi") {
+		score += ScoreExtension
+	}
+	return score
+}
+
+// Open cuts the levels of the pyramid into virtual tiles along their restart intervals.
+func (hamamatsuFormat) Open(r *SlideReader, metadata model.TIFFMetadata) (SlideMetadata, error) {
+	slide, err := partitionHamamatsu(metadata)
+	if err != nil {
+		return SlideMetadata{}, err
+	}
+	slide.Restarts, err = r.restartGrids(slide.Directories)
+	if err != nil {
+		return SlideMetadata{}, err
+	}
+	return slide, nil
+}
+
+// ReadTile assembles the virtual tiles of the levels split by restart markers.
+func (f hamamatsuFormat) ReadTile(r *SlideReader, slide SlideMetadata, levelIdx, tileIdx int) ([]byte, error) {
+	grid := slide.Restart(levelIdx)
+	if grid == nil {
+		return f.BaseFormat.ReadTile(r, slide, levelIdx, tileIdx)
+	}
+	level, err := slide.Level(levelIdx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get level %d: %w", levelIdx, err)
+	}
+	return r.getRestartTile(level, grid, tileIdx)
+}
+
+// partitionHamamatsu classifies the directories of a NDPI file from their NDPISourceLens:
+// the magnified images of the focal plane 0 form the pyramid, -1 is the macro.
+// Each level is a single JPEG strip, only the ones split by restart markers (NDPIMcuStarts) can be tiled.
+func partitionHamamatsu(metadata model.TIFFMetadata) (SlideMetadata, error) {
+	slide := SlideMetadata{
+		Vendor:     VendorHamamatsu,
+		Properties: make(map[string]string),
+	}
+
+	for _, directory := range metadata {
+		lens, err := directory.GetFloatTag(tags.NDPISourceLens)
+		if err != nil {
+			return SlideMetadata{}, fmt.Errorf("NDPI directory without source lens: %w", err)
+		}
+		focalPlane, _ := directory.GetFloatTag(tags.NDPIFocalPlane)
+		_, mcuStartsErr := directory.Tag(tags.NDPIMcuStarts)
+
+		switch {
+		case lens > 0 && focalPlane == 0 && mcuStartsErr == nil:
+			slide.Directories = append(slide.Directories, directory)
+		case lens == ndpiMacroLens:
+			slide.ExtraImages = append(slide.ExtraImages, directory)
+			slide.ExtraNames = append(slide.ExtraNames, AssociatedMacro)
+		default:
+			// map of the scanned area, other focal planes, levels without restart markers
+			slide.ExtraImages = append(slide.ExtraImages, directory)
+			slide.ExtraNames = append(slide.ExtraNames, "")
+		}
+	}
+	if len(slide.Directories) == 0 {
+		return SlideMetadata{}, errors.New("NDPI slide without pyramid levels")
+	}
+	slices.SortStableFunc(slide.Directories, func(a, b model.TIFFDirectory) int {
+		widthA, _ := a.GetImageWidth()
+		widthB, _ := b.GetImageWidth()
+		return cmp.Compare(widthB, widthA)
+	})
+
+	level := slide.Directories[0]
+	for tagID, name := range map[tags.TagID]string{
+		tags.NDPISourceLens:        "SourceLens",
+		tags.NDPIXOffsetFromCentre: "XOffsetFromSlideCentre",
+		tags.NDPIYOffsetFromCentre: "YOffsetFromSlideCentre",
+	} {
+		if v, err := level.GetFloatTag(tagID); err == nil {
+			slide.Properties[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	slide.ObjectivePower = parsePositiveFloat(slide.Properties["SourceLens"])
+	if reference, err := level.GetStringTag(tags.NDPIReference); err == nil {
+		slide.Properties["Reference"] = reference
+	}
+	if propertyMap, err := level.GetStringTag(tags.NDPIPropertyMap); err == nil {
+		parseNDPIPropertyMap(propertyMap, slide.Properties)
+	}
+	return slide, nil
+}
+
+// parseNDPIPropertyMap collects the key=value lines of the NDPIPropertyMap tag.
+func parseNDPIPropertyMap(propertyMap string, properties map[string]string) {
+	for _, line := range strings.FieldsFunc(propertyMap, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		if key, value, ok := strings.Cut(line, "="); ok && strings.TrimSpace(key) != "" {
+			properties[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+}
+
+// restartGrids cuts the levels split by restart markers into virtual tiles, nil for the other levels.
+func (r *SlideReader) restartGrids(levels model.TIFFMetadata) ([]*RestartGrid, error) {
+	var grids []*RestartGrid
+	for i, level := range levels {
+		if _, err := level.Tag(tags.NDPIMcuStarts); err != nil {
+			continue
+		}
+		grid, err := r.restartGrid(level)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the restart intervals of level %d: %w", i, err)
+		}
+		if grids == nil {
+			grids = make([]*RestartGrid, len(levels))
+		}
+		grids[i] = grid
+	}
+	return grids, nil
+}
+
+// restartGrid reads the JPEG header of the strip of the level and checks that its restart intervals form a grid.
+func (r *SlideReader) restartGrid(level model.TIFFDirectory) (*RestartGrid, error) {
+	imageTags, err := level.Tags(tags.ImageWidth, tags.ImageLength, tags.StripByteCounts, tags.NDPIMcuStarts)
+	if err != nil {
+		return nil, fmt.Errorf("missing required tags: %w", err)
+	}
+	if imageTags[2].ValuesCount() != 1 {
+		return nil, fmt.Errorf("%d strips, a single one expected", imageTags[2].ValuesCount())
+	}
+	mcuStarts, err := ndpiMcuStarts(level, imageTags[3])
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := r.reader.GetStripDataRange(level, 0, 0, mcuStarts[0])
+	if err != nil {
+		return nil, fmt.Errorf("unable to read JPEG header: %w", err)
+	}
+	scan, err := jpegio.ParseRestartScan(header)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse JPEG header: %w", err)
+	}
+
+	grid := &RestartGrid{
+		Scan:      scan,
+		McuStarts: mcuStarts,
+		StripSize: imageTags[2].GetUintVal(0),
+		Width:     int(imageTags[0].GetUintVal(0)),
+		Height:    int(imageTags[1].GetUintVal(0)),
+		TileWidth: scan.RestartInterval * scan.McuWidth,
+	}
+	mcuColumns := ceilDiv(grid.Width, scan.McuWidth)
+	if mcuColumns%scan.RestartInterval != 0 {
+		return nil, fmt.Errorf("restart interval of %d MCUs across rows of %d MCUs", scan.RestartInterval, mcuColumns)
+	}
+	grid.Columns = mcuColumns / scan.RestartInterval
+	grid.McuRows = ceilDiv(grid.Height, scan.McuHeight)
+	if len(mcuStarts) != grid.Columns*grid.McuRows {
+		return nil, fmt.Errorf("%d restart intervals, %dx%d expected", len(mcuStarts), grid.Columns, grid.McuRows)
+	}
+	grid.RowsPerTile = max(1, min(grid.TileWidth, ndpiMaxTileHeight)/scan.McuHeight)
+	grid.TileHeight = grid.RowsPerTile * scan.McuHeight
+	return grid, nil
+}
+
+// ndpiMcuStarts returns the offsets of the restart intervals in the strip, completed by their high bytes if any.
+func ndpiMcuStarts(level model.TIFFDirectory, tag model.TIFFTag) ([]uint64, error) {
+	highBytes, err := level.Tag(tags.NDPIMcuStartsHighBytes)
+	hasHighBytes := err == nil
+	if tag.ValuesCount() == 0 || (hasHighBytes && highBytes.ValuesCount() != tag.ValuesCount()) {
+		return nil, fmt.Errorf("invalid NDPIMcuStarts of %d values", tag.ValuesCount())
+	}
+
+	mcuStarts := make([]uint64, tag.ValuesCount())
+	for i := range mcuStarts {
+		v, err := tag.UintVal(i)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read NDPIMcuStarts: %w", err)
+		}
+		if hasHighBytes {
+			high, err := highBytes.UintVal(i)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read NDPIMcuStartsHighBytes: %w", err)
+			}
+			v |= high << 32
+		}
+		if i > 0 && v <= mcuStarts[i-1] {
+			return nil, fmt.Errorf("NDPIMcuStarts not increasing at %d", i)
+		}
+		mcuStarts[i] = v
+	}
+	return mcuStarts, nil
+}
+
+// getRestartTile assembles a virtual tile from the restart intervals it covers, cropped to the level.
+func (r *SlideReader) getRestartTile(level model.TIFFDirectory, grid *RestartGrid, tileIdx int) ([]byte, error) {
+	col, row := tileIdx%grid.Columns, tileIdx/grid.Columns
+	firstRow := row * grid.RowsPerTile
+	if tileIdx < 0 || firstRow >= grid.McuRows {
+		return nil, fmt.Errorf("getRestartTile: %w: %d", ErrTileOutOfRange, tileIdx)
+	}
+	rows := min(grid.RowsPerTile, grid.McuRows-firstRow)
+
+	intervals := make([][]byte, 0, rows)
+	for mcuRow := firstRow; mcuRow < firstRow+rows; mcuRow++ {
+		idx := mcuRow*grid.Columns + col
+		end := grid.StripSize
+		if idx+1 < len(grid.McuStarts) {
+			end = grid.McuStarts[idx+1]
+		}
+		data, err := r.reader.GetStripDataRange(level, 0, grid.McuStarts[idx], end-grid.McuStarts[idx])
+		if err != nil {
+			return nil, fmt.Errorf("getRestartTile: %w", err)
+		}
+		intervals = append(intervals, data)
+	}
+
+	width := min(grid.TileWidth, grid.Width-col*grid.TileWidth)
+	height := min(grid.TileHeight, grid.Height-row*grid.TileHeight)
+	return grid.Scan.Compose(width, height, intervals), nil
+}
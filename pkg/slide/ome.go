@@ -0,0 +1,415 @@
+package slide
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	VendorOME = "ome"
+
+	omeNamespace           = "openmicroscopy.org/Schemas/OME"
+	omeDefaultOrder        = "XYCZT"
+	omeDefaultPhysicalUnit = "µm"
+)
+
+// Series is an image of an OME-TIFF file. Its planes are indexed by channel, focal plane and timepoint,
+// each plane being a pyramid whose reduced resolutions are stored in the SubIFDs of the full one.
+type Series struct {
+	ID             string       `json:"id"`
+	Name           string       `json:"name"`
+	DimensionOrder string       `json:"dimensionOrder"` // order of the planes in the IFDs, i.e. XYCZT
+	PixelType      string       `json:"pixelType"`      // type of the samples, i.e. uint8, uint16
+	SizeX          int          `json:"sizeX"`
+	SizeY          int          `json:"sizeY"`
+	SizeC          int          `json:"sizeC"` // number of channels, samples of an RGB channel being counted once
+	SizeZ          int          `json:"sizeZ"`
+	SizeT          int          `json:"sizeT"`
+	PhysicalSizeX  PhysicalSize `json:"physicalSizeX"` // size of a pixel, zero when unknown
+	PhysicalSizeY  PhysicalSize `json:"physicalSizeY"`
+	PhysicalSizeZ  PhysicalSize `json:"physicalSizeZ"` // distance between the focal planes
+	Channels       []Channel    `json:"channels"`
+
+	planes map[Plane]model.TIFFMetadata // levels of each plane, from the largest to the smallest
+}
+
+// PhysicalSize is a length with its unit, i.e. 0.325 µm.
+type PhysicalSize struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// omeLengthUnits are the lengths in micrometres of the units of the physical sizes.
+var omeLengthUnits = map[string]float64{
+	"m":  1e6,
+	"cm": 1e4,
+	"mm": 1e3,
+	"µm": 1,
+	"um": 1,
+	"nm": 1e-3,
+	"pm": 1e-6,
+	"Å":  1e-4,
+}
+
+// Micrometres converts the size to micrometres, zero when it is unknown.
+func (s PhysicalSize) Micrometres() float64 {
+	if unit, ok := omeLengthUnits[s.Unit]; ok && s.Value > 0 {
+		return s.Value * unit
+	}
+	return 0
+}
+
+// Channel describes a channel of a series, i.e. a fluorescence stain.
+type Channel struct {
+	ID                   string  `json:"id"`
+	Name                 string  `json:"name"`
+	Color                string  `json:"color"` // RGBA as #rrggbbaa
+	SamplesPerPixel      int     `json:"samplesPerPixel"`
+	ExcitationWavelength float64 `json:"excitationWavelength,omitempty"`
+	EmissionWavelength   float64 `json:"emissionWavelength,omitempty"`
+	Fluor                string  `json:"fluor,omitempty"`
+}
+
+// Plane locates a 2D plane of a series.
+type Plane struct {
+	C int // channel
+	Z int // focal plane
+	T int // timepoint
+}
+
+// TileAddress locates a tile of an OME-TIFF file.
+type TileAddress struct {
+	Series  int
+	Channel int
+	Z       int
+	T       int
+	Level   int
+	X       int
+	Y       int
+}
+
+// Levels returns the pyramid of a plane of the series.
+func (s Series) Levels(plane Plane) (model.TIFFMetadata, error) {
+	levels, ok := s.planes[plane]
+	if !ok {
+		return nil, fmt.Errorf("%w: c=%d z=%d t=%d", ErrPlaneOutOfRange, plane.C, plane.Z, plane.T)
+	}
+	return levels, nil
+}
+
+// omeXML is the OME-XML ImageDescription of the first directory of an OME-TIFF file, i.e.
+//
+//	<OME xmlns="http://www.openmicroscopy.org/Schemas/OME/2016-06" UUID="urn:uuid:...">
+//	  <Image ID="Image:0" Name="...">
+//	    <Pixels DimensionOrder="XYCZT" Type="uint16" SizeX="..." SizeY="..." SizeC="3" SizeZ="1" SizeT="1"
+//	            PhysicalSizeX="0.325" PhysicalSizeXUnit="µm" ...>
+//	      <Channel ID="Channel:0:0" Name="DAPI" Color="65535" SamplesPerPixel="1"/>
+//	      ...
+//	      <TiffData IFD="0" FirstC="0" FirstZ="0" FirstT="0" PlaneCount="1"/>
+//	      ...
+type omeXML struct {
+	UUID   string     `xml:"UUID,attr"`
+	Images []omeImage `xml:"Image"`
+}
+
+type omeImage struct {
+	ID     string `xml:"ID,attr"`
+	Name   string `xml:"Name,attr"`
+	Pixels struct {
+		DimensionOrder    string        `xml:"DimensionOrder,attr"`
+		Type              string        `xml:"Type,attr"`
+		SizeX             int           `xml:"SizeX,attr"`
+		SizeY             int           `xml:"SizeY,attr"`
+		SizeC             int           `xml:"SizeC,attr"`
+		SizeZ             int           `xml:"SizeZ,attr"`
+		SizeT             int           `xml:"SizeT,attr"`
+		PhysicalSizeX     float64       `xml:"PhysicalSizeX,attr"`
+		PhysicalSizeXUnit string        `xml:"PhysicalSizeXUnit,attr"`
+		PhysicalSizeY     float64       `xml:"PhysicalSizeY,attr"`
+		PhysicalSizeYUnit string        `xml:"PhysicalSizeYUnit,attr"`
+		PhysicalSizeZ     float64       `xml:"PhysicalSizeZ,attr"`
+		PhysicalSizeZUnit string        `xml:"PhysicalSizeZUnit,attr"`
+		Channels          []omeChannel  `xml:"Channel"`
+		TiffData          []omeTiffData `xml:"TiffData"`
+	} `xml:"Pixels"`
+}
+
+type omeChannel struct {
+	ID                   string  `xml:"ID,attr"`
+	Name                 string  `xml:"Name,attr"`
+	Color                *int32  `xml:"Color,attr"`
+	SamplesPerPixel      int     `xml:"SamplesPerPixel,attr"`
+	ExcitationWavelength float64 `xml:"ExcitationWavelength,attr"`
+	EmissionWavelength   float64 `xml:"EmissionWavelength,attr"`
+	Fluor                string  `xml:"Fluor,attr"`
+}
+
+// omeTiffData maps PlaneCount planes, from (FirstC, FirstZ, FirstT) in the dimension order, to the IFDs from IFD.
+// The planes stored in another file of a multi-file dataset are referenced by the UUID of that file.
+type omeTiffData struct {
+	IFD        *int `xml:"IFD,attr"`
+	FirstC     int  `xml:"FirstC,attr"`
+	FirstZ     int  `xml:"FirstZ,attr"`
+	FirstT     int  `xml:"FirstT,attr"`
+	PlaneCount *int `xml:"PlaneCount,attr"`
+	UUID       struct {
+		FileName string `xml:"FileName,attr"`
+		Value    string `xml:",chardata"`
+	} `xml:"UUID"`
+}
+
+func init() {
+	RegisterFormat(omeFormat{})
+}
+
+// omeFormat reads the OME-TIFF files of the Open Microscopy Environment.
+type omeFormat struct {
+	BaseFormat
+}
+
+func (omeFormat) Name() string {
+	return VendorOME
+}
+
+// Detect recognises an OME-TIFF file from the OME-XML ImageDescription of its first directory.
+func (omeFormat) Detect(probe Probe) int {
+	score := ScoreNone
+	if strings.Contains(probe.ImageDescription, "<OME") && strings.Contains(probe.ImageDescription, omeNamespace) {
+		score += ScoreDescription
+	}
+	if probe.HasExtension(".ome.tif", ".ome.tiff", ".ome.tf2", ".ome.tf8", ".ome.btf") {
+		score += ScoreExtension
+	}
+	return score
+}
+
+func (omeFormat) Open(_ *SlideReader, metadata model.TIFFMetadata) (SlideMetadata, error) {
+	return partitionOME(metadata)
+}
+
+// partitionOME maps the planes of the images described by the OME-XML to the directories of the file.
+// The first plane of the first image is the default pyramid, the images named label, macro or thumbnail
+// (as written by Bio-Formats for whole slide images) are associated images.
+func partitionOME(metadata model.TIFFMetadata) (SlideMetadata, error) {
+	if len(metadata) == 0 {
+		return SlideMetadata{}, errNoDirectory
+	}
+	description, _ := metadata[0].GetStringTag(tags.ImageDescription)
+	var ome omeXML
+	if err := xml.Unmarshal([]byte(description), &ome); err != nil {
+		return SlideMetadata{}, fmt.Errorf("unable to parse OME-XML: %w", err)
+	}
+
+	slide := SlideMetadata{
+		Vendor:     VendorOME,
+		Properties: map[string]string{"UUID": ome.UUID},
+	}
+	for _, omeImage := range ome.Images {
+		series, err := newSeries(omeImage, ome.UUID, metadata)
+		if err != nil {
+			slog.Warn("Unable to map OME image to the IFDs, ignored", "image", omeImage.ID, "error", err)
+			continue
+		}
+		slide.Series = append(slide.Series, series)
+
+		levels, err := series.Levels(Plane{})
+		if err != nil {
+			continue
+		}
+		if name := omeAssociatedName(series.Name); name != "" {
+			slide.ExtraImages = append(slide.ExtraImages, levels[0])
+			slide.ExtraNames = append(slide.ExtraNames, name)
+			continue
+		}
+		if slide.Directories == nil {
+			slide.Directories = levels
+			addOMEProperties(series, len(slide.Series)-1, slide.Properties)
+			slide.MppX, slide.MppY = series.PhysicalSizeX.Micrometres(), series.PhysicalSizeY.Micrometres()
+		}
+	}
+	if slide.Directories == nil {
+		return SlideMetadata{}, errors.New("OME-TIFF without image")
+	}
+	return slide, nil
+}
+
+// newSeries maps the planes of an OME image to the directories, following its TiffData elements.
+// An image without TiffData is mapped as the OME specification defines: its planes are the IFDs from the first one.
+func newSeries(omeImage omeImage, uuid string, metadata model.TIFFMetadata) (Series, error) {
+	pixels := omeImage.Pixels
+	series := Series{
+		ID:             omeImage.ID,
+		Name:           omeImage.Name,
+		DimensionOrder: cmp.Or(pixels.DimensionOrder, omeDefaultOrder),
+		PixelType:      pixels.Type,
+		SizeX:          pixels.SizeX,
+		SizeY:          pixels.SizeY,
+		SizeC:          max(pixels.SizeC, 1),
+		SizeZ:          max(pixels.SizeZ, 1),
+		SizeT:          max(pixels.SizeT, 1),
+		PhysicalSizeX:  PhysicalSize{Value: pixels.PhysicalSizeX, Unit: cmp.Or(pixels.PhysicalSizeXUnit, omeDefaultPhysicalUnit)},
+		PhysicalSizeY:  PhysicalSize{Value: pixels.PhysicalSizeY, Unit: cmp.Or(pixels.PhysicalSizeYUnit, omeDefaultPhysicalUnit)},
+		PhysicalSizeZ:  PhysicalSize{Value: pixels.PhysicalSizeZ, Unit: cmp.Or(pixels.PhysicalSizeZUnit, omeDefaultPhysicalUnit)},
+		planes:         make(map[Plane]model.TIFFMetadata),
+	}
+	if !isDimensionOrder(series.DimensionOrder) {
+		return Series{}, fmt.Errorf("invalid DimensionOrder: %s", series.DimensionOrder)
+	}
+
+	// the samples of an RGB channel are stored in the same plane
+	samples := 0
+	for _, omeChannel := range pixels.Channels {
+		channel := Channel{
+			ID:                   omeChannel.ID,
+			Name:                 omeChannel.Name,
+			Color:                omeColor(omeChannel.Color),
+			SamplesPerPixel:      max(omeChannel.SamplesPerPixel, 1),
+			ExcitationWavelength: omeChannel.ExcitationWavelength,
+			EmissionWavelength:   omeChannel.EmissionWavelength,
+			Fluor:                omeChannel.Fluor,
+		}
+		samples += channel.SamplesPerPixel
+		series.Channels = append(series.Channels, channel)
+	}
+	if len(series.Channels) > 0 && samples == series.SizeC {
+		series.SizeC = len(series.Channels)
+	}
+
+	planeCount := series.SizeC * series.SizeZ * series.SizeT
+	tiffDataElements := pixels.TiffData
+	if len(tiffDataElements) == 0 {
+		// without TiffData, the planes are stored in the IFDs in order from the first one
+		tiffDataElements = []omeTiffData{{}}
+	}
+	for _, tiffData := range tiffDataElements {
+		if tiffData.UUID.Value != "" && uuid != "" && strings.TrimSpace(tiffData.UUID.Value) != uuid {
+			return Series{}, fmt.Errorf("planes stored in another file: %s", tiffData.UUID.FileName)
+		}
+		ifd, count := 0, 1
+		if tiffData.IFD != nil {
+			ifd = *tiffData.IFD
+		}
+		switch {
+		case tiffData.PlaneCount != nil:
+			count = *tiffData.PlaneCount
+		case tiffData.IFD == nil:
+			count = planeCount // all the planes from the first IFD
+		}
+
+		first := series.planeIndex(Plane{C: tiffData.FirstC, Z: tiffData.FirstZ, T: tiffData.FirstT})
+		for i := range count {
+			if first+i >= planeCount || ifd+i < 0 || ifd+i >= len(metadata) {
+				return Series{}, fmt.Errorf("TiffData of %d planes from IFD %d out of range", count, ifd)
+			}
+			series.planes[series.plane(first+i)] = omeLevels(metadata[ifd+i])
+		}
+	}
+	return series, nil
+}
+
+// isDimensionOrder tells if order is XY followed by a permutation of CZT.
+func isDimensionOrder(order string) bool {
+	if len(order) != 5 || !strings.HasPrefix(order, "XY") {
+		return false
+	}
+	return strings.ContainsRune(order, 'C') && strings.ContainsRune(order, 'Z') && strings.ContainsRune(order, 'T')
+}
+
+// planeIndex returns the rank of the plane in the IFDs, the first dimension after XY varying the fastest.
+func (s Series) planeIndex(plane Plane) int {
+	idx := 0
+	for i := 4; i >= 2; i-- {
+		value, size := s.dimension(s.DimensionOrder[i], plane)
+		idx = idx*size + value
+	}
+	return idx
+}
+
+// plane returns the plane at rank idx in the IFDs, see planeIndex.
+func (s Series) plane(idx int) Plane {
+	var plane Plane
+	for i := 2; i <= 4; i++ {
+		_, size := s.dimension(s.DimensionOrder[i], plane)
+		value := idx % size
+		idx /= size
+		switch s.DimensionOrder[i] {
+		case 'C':
+			plane.C = value
+		case 'Z':
+			plane.Z = value
+		case 'T':
+			plane.T = value
+		}
+	}
+	return plane
+}
+
+// dimension returns the coordinate of the plane along the dimension, and the size of the dimension.
+func (s Series) dimension(dimension byte, plane Plane) (int, int) {
+	switch dimension {
+	case 'C':
+		return plane.C, s.SizeC
+	case 'Z':
+		return plane.Z, s.SizeZ
+	default:
+		return plane.T, s.SizeT
+	}
+}
+
+// omeLevels returns the directory of a plane followed by its SubIFDs, from the largest to the smallest.
+func omeLevels(directory model.TIFFDirectory) model.TIFFMetadata {
+	levels := slices.Concat(model.TIFFMetadata{directory}, directory.SubIFDs())
+	slices.SortStableFunc(levels, func(a, b model.TIFFDirectory) int {
+		widthA, _ := a.GetImageWidth()
+		widthB, _ := b.GetImageWidth()
+		return cmp.Compare(widthB, widthA)
+	})
+	return levels
+}
+
+// omeColor formats the signed RGBA integer of a channel as #rrggbbaa, white when unset.
+func omeColor(color *int32) string {
+	if color == nil {
+		return "#ffffffff"
+	}
+	return fmt.Sprintf("#%08x", uint32(*color))
+}
+
+// omeAssociatedName returns the kind of an image from its name, empty for the images of the slide.
+func omeAssociatedName(name string) string {
+	switch name = strings.ToLower(name); name {
+	case AssociatedLabel, AssociatedMacro, AssociatedThumbnail:
+		return name
+	}
+	return ""
+}
+
+// addOMEProperties collects the description of the default series at index idx, keyed after the OME-XML elements.
+func addOMEProperties(series Series, idx int, properties map[string]string) {
+	properties["Series"] = strconv.Itoa(idx)
+	properties["Image.ID"] = series.ID
+	properties["Image.Name"] = series.Name
+	properties["Pixels.DimensionOrder"] = series.DimensionOrder
+	properties["Pixels.Type"] = series.PixelType
+	properties["Pixels.SizeC"] = strconv.Itoa(series.SizeC)
+	properties["Pixels.SizeZ"] = strconv.Itoa(series.SizeZ)
+	properties["Pixels.SizeT"] = strconv.Itoa(series.SizeT)
+	for name, size := range map[string]PhysicalSize{"X": series.PhysicalSizeX, "Y": series.PhysicalSizeY, "Z": series.PhysicalSizeZ} {
+		if size.Value > 0 {
+			properties["Pixels.PhysicalSize"+name] = strconv.FormatFloat(size.Value, 'f', -1, 64)
+			properties["Pixels.PhysicalSize"+name+"Unit"] = size.Unit
+		}
+	}
+	for i, channel := range series.Channels {
+		properties[fmt.Sprintf("Channel[%d].Name", i)] = channel.Name
+		properties[fmt.Sprintf("Channel[%d].Color", i)] = channel.Color
+	}
+}
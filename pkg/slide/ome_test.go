@@ -0,0 +1,111 @@
+package slide
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+)
+
+func TestNewSeriesPlanes(t *testing.T) {
+	// IFD i is recognised by its width, 1000+i
+	metadata := make(model.TIFFMetadata, 4)
+	for i := range metadata {
+		metadata[i] = model.NewTIFFDirectory(map[tags.TagID]model.TIFFTag{
+			tags.ImageWidth: model.DataTag[uint32]{TagID: tags.ImageWidth, Values: []uint32{uint32(1000 + i)}},
+		})
+	}
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name      string
+		order     string
+		sizeC     int
+		sizeZ     int
+		tiffData  []omeTiffData
+		wantIFDs  map[Plane]int // IFD of each plane
+		wantError bool
+	}{
+		{
+			name:     "no TiffData, planes from the first IFD",
+			order:    "XYCZT",
+			sizeC:    2,
+			sizeZ:    2,
+			wantIFDs: map[Plane]int{{C: 0, Z: 0}: 0, {C: 1, Z: 0}: 1, {C: 0, Z: 1}: 2, {C: 1, Z: 1}: 3},
+		},
+		{
+			name:     "no TiffData, Z varying the fastest",
+			order:    "XYZCT",
+			sizeC:    2,
+			sizeZ:    2,
+			wantIFDs: map[Plane]int{{C: 0, Z: 0}: 0, {C: 0, Z: 1}: 1, {C: 1, Z: 0}: 2, {C: 1, Z: 1}: 3},
+		},
+		{
+			name:      "no TiffData, more planes than IFDs",
+			order:     "XYCZT",
+			sizeC:     3,
+			sizeZ:     2,
+			wantError: true,
+		},
+		{
+			name:     "TiffData of a single IFD each",
+			order:    "XYCZT",
+			sizeC:    2,
+			sizeZ:    1,
+			tiffData: []omeTiffData{{IFD: intPtr(3), FirstC: 0}, {IFD: intPtr(1), FirstC: 1}},
+			wantIFDs: map[Plane]int{{C: 0}: 3, {C: 1}: 1},
+		},
+		{
+			name:     "TiffData with PlaneCount",
+			order:    "XYCZT",
+			sizeC:    3,
+			sizeZ:    1,
+			tiffData: []omeTiffData{{IFD: intPtr(1), PlaneCount: intPtr(3)}},
+			wantIFDs: map[Plane]int{{C: 0}: 1, {C: 1}: 2, {C: 2}: 3},
+		},
+		{
+			name:      "TiffData out of range",
+			order:     "XYCZT",
+			sizeC:     2,
+			sizeZ:     1,
+			tiffData:  []omeTiffData{{IFD: intPtr(3), PlaneCount: intPtr(2)}},
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var image omeImage
+			image.Pixels.DimensionOrder = tt.order
+			image.Pixels.SizeC, image.Pixels.SizeZ, image.Pixels.SizeT = tt.sizeC, tt.sizeZ, 1
+			image.Pixels.TiffData = tt.tiffData
+
+			series, err := newSeries(image, "", metadata)
+			if tt.wantError {
+				if err == nil {
+					t.Fatal("newSeries succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newSeries: %v", err)
+			}
+			if len(series.planes) != len(tt.wantIFDs) {
+				t.Errorf("%d planes mapped, want %d", len(series.planes), len(tt.wantIFDs))
+			}
+			for plane, ifd := range tt.wantIFDs {
+				levels, err := series.Levels(plane)
+				if err != nil {
+					t.Errorf("Levels(%+v): %v", plane, err)
+					continue
+				}
+				if width, _ := levels[0].GetImageWidth(); width != 1000+ifd {
+					t.Errorf("Levels(%+v) is IFD %d, want %d", plane, width-1000, ifd)
+				}
+			}
+			if _, err := series.Levels(Plane{C: tt.sizeC}); !errors.Is(err, ErrPlaneOutOfRange) {
+				t.Errorf("Levels of channel %d: %v, want %v", tt.sizeC, err, ErrPlaneOutOfRange)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package slide
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"image"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	VendorPhilips = "philips"
+
+	philipsSoftwarePrefix = "Philips"
+	philipsRootObjectType = "DPUfsImport"
+
+	philipsImageWSI   = "WSI"
+	philipsImageLabel = "LABELIMAGE"
+	philipsImageMacro = "MACROIMAGE"
+)
+
+// philipsDataObject is a DICOM-like object of the Philips XML ImageDescription, i.e.
+//
+//	<DataObject ObjectType="DPUfsImport">
+//	  <Attribute Name="DICOM_MANUFACTURER" Group="0x0008" Element="0x0070" PMSVR="IString">PHILIPS</Attribute>
+//	  <Attribute Name="PIM_DP_SCANNED_IMAGES" Group="0x301D" Element="0x1003" PMSVR="IDataObjectArray">
+//	    <Array><DataObject ObjectType="DPScannedImage">...</DataObject></Array>
+//	  </Attribute>
+//	</DataObject>
+type philipsDataObject struct {
+	ObjectType string             `xml:"ObjectType,attr"`
+	Attributes []philipsAttribute `xml:"Attribute"`
+}
+
+type philipsAttribute struct {
+	Name    string              `xml:"Name,attr"`
+	Value   string              `xml:",chardata"`
+	Objects []philipsDataObject `xml:"Array>DataObject"`
+}
+
+func (o philipsDataObject) attribute(name string) (philipsAttribute, bool) {
+	for _, a := range o.Attributes {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return philipsAttribute{}, false
+}
+
+func (o philipsDataObject) value(name string) string {
+	a, _ := o.attribute(name)
+	return strings.TrimSpace(a.Value)
+}
+
+func init() {
+	RegisterFormat(philipsFormat{})
+}
+
+// philipsFormat reads the TIFF files exported by the Philips IntelliSite pathology solution.
+type philipsFormat struct {
+	BaseFormat
+}
+
+func (philipsFormat) Name() string {
+	return VendorPhilips
+}
+
+// Detect recognises a Philips TIFF from the Software tag or the XML ImageDescription of its first directory.
+func (philipsFormat) Detect(probe Probe) int {
+	score := ScoreNone
+	if strings.HasPrefix(probe.Software, philipsSoftwarePrefix) {
+		score += ScoreTag
+	}
+	if strings.HasPrefix(probe.ImageDescription, "<?xml") && strings.Contains(probe.ImageDescription, philipsRootObjectType) {
+		score += ScoreDescription
+	}
+	return score
+}
+
+func (philipsFormat) Open(_ *SlideReader, metadata model.TIFFMetadata) (SlideMetadata, error) {
+	return partitionPhilips(metadata)
+}
+
+// partitionPhilips classifies the directories of a Philips TIFF.
+// The tiled directories form the pyramid, whose true dimensions are derived from the pixel spacing of each level.
+// The label and the macro are either embedded in the XML or stored as stripped directories.
+func partitionPhilips(metadata model.TIFFMetadata) (SlideMetadata, error) {
+	if len(metadata) == 0 {
+		return SlideMetadata{}, errNoDirectory
+	}
+	description, _ := metadata[0].GetStringTag(tags.ImageDescription)
+	var root philipsDataObject
+	if err := xml.Unmarshal([]byte(description), &root); err != nil {
+		return SlideMetadata{}, fmt.Errorf("unable to parse Philips ImageDescription: %w", err)
+	}
+
+	slide := SlideMetadata{
+		Vendor:         VendorPhilips,
+		Properties:     make(map[string]string),
+		AssociatedData: make(map[string][]byte),
+	}
+	flattenPhilipsProperties(root, "", slide.Properties)
+
+	for _, directory := range metadata {
+		if _, err := directory.GetTileWidth(); err == nil {
+			slide.Directories = append(slide.Directories, directory)
+			continue
+		}
+		slide.ExtraImages = append(slide.ExtraImages, directory)
+		slide.ExtraNames = append(slide.ExtraNames, philipsAssociatedName(directory))
+	}
+	slices.SortStableFunc(slide.Directories, func(a, b model.TIFFDirectory) int {
+		widthA, _ := a.GetImageWidth()
+		widthB, _ := b.GetImageWidth()
+		return cmp.Compare(widthB, widthA)
+	})
+
+	scannedImages, _ := root.attribute("PIM_DP_SCANNED_IMAGES")
+	for _, scannedImage := range scannedImages.Objects {
+		switch imageType := scannedImage.value("PIM_DP_IMAGE_TYPE"); imageType {
+		case philipsImageWSI:
+			slide.Sizes = philipsLevelSizes(scannedImage, slide.Directories)
+			slide.MppX, slide.MppY = philipsMpp(scannedImage)
+		case philipsImageLabel, philipsImageMacro:
+			data, err := base64.StdEncoding.DecodeString(scannedImage.value("PIM_DP_IMAGE_DATA"))
+			if err != nil || len(data) == 0 {
+				continue
+			}
+			name := AssociatedLabel
+			if imageType == philipsImageMacro {
+				name = AssociatedMacro
+			}
+			slide.AssociatedData[name] = data
+		}
+	}
+	return slide, nil
+}
+
+// flattenPhilipsProperties collects the attributes of the object, the nested ones being named
+// after their path, i.e. PIM_DP_SCANNED_IMAGES[0].PIM_DP_IMAGE_TYPE. Embedded images are skipped.
+func flattenPhilipsProperties(object philipsDataObject, prefix string, properties map[string]string) {
+	for _, a := range object.Attributes {
+		if a.Name == "" || a.Name == "PIM_DP_IMAGE_DATA" {
+			continue
+		}
+		if len(a.Objects) == 0 {
+			properties[prefix+a.Name] = strings.TrimSpace(a.Value)
+			continue
+		}
+		for i, child := range a.Objects {
+			flattenPhilipsProperties(child, fmt.Sprintf("%s%s[%d].", prefix, a.Name, i), properties)
+		}
+	}
+}
+
+// philipsAssociatedName returns the kind of a stripped directory, named by its ImageDescription.
+func philipsAssociatedName(directory model.TIFFDirectory) string {
+	description, _ := directory.GetStringTag(tags.ImageDescription)
+	switch {
+	case strings.HasPrefix(description, "Label"):
+		return AssociatedLabel
+	case strings.HasPrefix(description, "Macro"):
+		return AssociatedMacro
+	}
+	return ""
+}
+
+// philipsLevelSizes computes the true dimensions of the levels: Philips pads every level to the tile size,
+// the dimensions are derived from the level 0 ones and the pixel spacing of the level.
+func philipsLevelSizes(scannedImage philipsDataObject, levels model.TIFFMetadata) []image.Point {
+	if len(levels) == 0 {
+		return nil
+	}
+	width, _ := levels[0].GetImageWidth()
+	height, _ := levels[0].GetImageHeight()
+	if columns, err := strconv.Atoi(scannedImage.value("PIM_DP_IMAGE_COLUMNS")); err == nil && columns > 0 {
+		width = min(width, columns)
+	}
+	if rows, err := strconv.Atoi(scannedImage.value("PIM_DP_IMAGE_ROWS")); err == nil && rows > 0 {
+		height = min(height, rows)
+	}
+
+	representations, _ := scannedImage.attribute("PIXEL_DATA_REPRESENTATION_SEQUENCE")
+	spacings := make([]float64, len(representations.Objects))
+	for _, representation := range representations.Objects {
+		number, err := strconv.Atoi(representation.value("PIIM_PIXEL_DATA_REPRESENTATION_NUMBER"))
+		if err != nil || number < 0 || number >= len(spacings) {
+			continue
+		}
+		spacings[number] = philipsPixelSpacing(representation.value("DICOM_PIXEL_SPACING"))
+	}
+	if len(spacings) != len(levels) || slices.Contains(spacings, 0) {
+		slog.Warn("Philips pixel spacing does not describe the pyramid, tiled extents used", "levels", len(levels), "representations", len(spacings))
+		return nil
+	}
+
+	sizes := make([]image.Point, len(levels))
+	for i, level := range levels {
+		downsample := spacings[i] / spacings[0]
+		levelWidth, _ := level.GetImageWidth()
+		levelHeight, _ := level.GetImageHeight()
+		sizes[i] = image.Point{
+			X: min(levelWidth, int(math.Ceil(float64(width)/downsample))),
+			Y: min(levelHeight, int(math.Ceil(float64(height)/downsample))),
+		}
+	}
+	return sizes
+}
+
+// philipsMpp returns the microns per pixel of the level 0 from its DICOM_PIXEL_SPACING,
+// the spacing between the rows being followed by the spacing between the columns.
+func philipsMpp(scannedImage philipsDataObject) (float64, float64) {
+	representations, _ := scannedImage.attribute("PIXEL_DATA_REPRESENTATION_SEQUENCE")
+	for _, representation := range representations.Objects {
+		if representation.value("PIIM_PIXEL_DATA_REPRESENTATION_NUMBER") != "0" {
+			continue
+		}
+		fields := strings.Fields(representation.value("DICOM_PIXEL_SPACING"))
+		if len(fields) == 0 {
+			return 0, 0
+		}
+		rows := parsePositiveFloat(strings.Trim(fields[0], `"`))
+		columns := rows
+		if len(fields) > 1 {
+			columns = parsePositiveFloat(strings.Trim(fields[1], `"`))
+		}
+		return 1000 * columns, 1000 * rows
+	}
+	return 0, 0
+}
+
+// philipsPixelSpacing returns the first value of a DICOM_PIXEL_SPACING attribute, i.e. "0.000227273" "0.000227273",
+// in millimeters per pixel.
+func philipsPixelSpacing(value string) float64 {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	spacing, err := strconv.ParseFloat(strings.Trim(fields[0], `"`), 64)
+	if err != nil || spacing <= 0 {
+		return 0
+	}
+	return spacing
+}
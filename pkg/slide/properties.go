@@ -0,0 +1,127 @@
+package slide
+
+import (
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"strconv"
+	"strings"
+)
+
+// Keys of the normalised properties, named as the OpenSlide ones.
+const (
+	PropertyVendor         = "openslide.vendor"
+	PropertyComment        = "openslide.comment"
+	PropertyMppX           = "openslide.mpp-x"
+	PropertyMppY           = "openslide.mpp-y"
+	PropertyObjectivePower = "openslide.objective-power"
+	PropertyBoundsX        = "openslide.bounds-x"
+	PropertyBoundsY        = "openslide.bounds-y"
+	PropertyBoundsWidth    = "openslide.bounds-width"
+	PropertyBoundsHeight   = "openslide.bounds-height"
+	PropertyLevelCount     = "openslide.level-count"
+)
+
+// openslideGenericVendor is the OpenSlide name of the TIFF files of no known vendor.
+const openslideGenericVendor = "generic-tiff"
+
+// tiffStringProperties are the ASCII tags of the level 0 exposed as tiff.<name> properties.
+var tiffStringProperties = []tags.TagID{
+	tags.ImageDescription,
+	tags.Make,
+	tags.Model,
+	tags.Software,
+	tags.DateTime,
+	tags.Artist,
+	tags.HostComputer,
+	tags.Copyright,
+	tags.DocumentName,
+}
+
+// Properties returns the properties of the slide keyed as the OpenSlide ones:
+//   - openslide.* normalised values, i.e. openslide.mpp-x, openslide.objective-power, openslide.level[0].width
+//   - tiff.* standard tags of the level 0, i.e. tiff.Make, tiff.XResolution
+//   - <vendor>.* raw properties of the vendor description, i.e. aperio.AppMag, see GetVendorProperties
+func (r *SlideReader) Properties() map[string]string {
+	properties := make(map[string]string)
+	vendor := r.pyramid.Vendor
+	if vendor == VendorGeneric {
+		vendor = openslideGenericVendor
+	}
+	properties[PropertyVendor] = vendor
+	for key, value := range r.format.Properties(r.pyramid) {
+		properties[vendor+"."+key] = value
+	}
+
+	if levels := r.format.Levels(r.pyramid); len(levels) > 0 {
+		addTIFFProperties(levels[0], properties)
+	}
+
+	if mppX, mppY := slideMpp(r.pyramid); mppX > 0 && mppY > 0 {
+		properties[PropertyMppX] = formatFloat(mppX)
+		properties[PropertyMppY] = formatFloat(mppY)
+	}
+	if r.pyramid.ObjectivePower > 0 {
+		properties[PropertyObjectivePower] = formatFloat(r.pyramid.ObjectivePower)
+	}
+	if bounds := r.pyramid.Bounds; !bounds.Empty() {
+		properties[PropertyBoundsX] = strconv.Itoa(bounds.Min.X)
+		properties[PropertyBoundsY] = strconv.Itoa(bounds.Min.Y)
+		properties[PropertyBoundsWidth] = strconv.Itoa(bounds.Dx())
+		properties[PropertyBoundsHeight] = strconv.Itoa(bounds.Dy())
+	}
+
+	if pyramid, err := pyramidMetadata(r.pyramid); err == nil {
+		properties[PropertyLevelCount] = strconv.Itoa(len(pyramid.Levels))
+		for i, level := range pyramid.Levels {
+			prefix := fmt.Sprintf("openslide.level[%d].", i)
+			properties[prefix+"width"] = strconv.Itoa(level.ImageWidth)
+			properties[prefix+"height"] = strconv.Itoa(level.ImageHeight)
+			properties[prefix+"downsample"] = formatFloat(level.Downsample)
+			properties[prefix+"tile-width"] = strconv.Itoa(level.TileWidth)
+			properties[prefix+"tile-height"] = strconv.Itoa(level.TileHeight)
+		}
+	}
+	return properties
+}
+
+// addTIFFProperties adds the standard tags of the directory as tiff.<name> properties,
+// the ImageDescription being also the openslide.comment.
+func addTIFFProperties(directory model.TIFFDirectory, properties map[string]string) {
+	for _, tagID := range tiffStringProperties {
+		if value, err := directory.GetStringTag(tagID); err == nil && value != "" {
+			properties["tiff."+tags.IDsLabels[tagID]] = value
+		}
+	}
+	if description, ok := properties["tiff.ImageDescription"]; ok {
+		properties[PropertyComment] = description
+	}
+	for _, tagID := range []tags.TagID{tags.XResolution, tags.YResolution} {
+		if value, err := directory.GetFloatTag(tagID); err == nil {
+			properties["tiff."+tags.IDsLabels[tagID]] = formatFloat(value)
+		}
+	}
+	if _, err := directory.Tag(tags.ResolutionUnit); err == nil {
+		switch directory.GetResolutionUnit() {
+		case tags.ResolutionUnitTypeNone:
+			properties["tiff.ResolutionUnit"] = "none"
+		case tags.ResolutionUnitTypeInch:
+			properties["tiff.ResolutionUnit"] = "inch"
+		case tags.ResolutionUnitTypeCentimeter:
+			properties["tiff.ResolutionUnit"] = "centimeter"
+		}
+	}
+}
+
+// parsePositiveFloat parses a number of a vendor description, zero when it is not a positive number.
+func parsePositiveFloat(value string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	return v
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
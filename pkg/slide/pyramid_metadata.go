@@ -0,0 +1,53 @@
+package slide
+
+import "fmt"
+
+type PyramidMetadata struct {
+	Levels []PyramidImage
+}
+
+type PyramidImage struct {
+	ImageWidth          int
+	ImageHeight         int
+	TileWidth           int
+	TileHeight          int
+	TileCountHorizontal int
+	TileCountVertical   int
+	Downsample          float64 // ratio of the dimensions of the level 0 to the ones of the level
+	MppX                float64 // microns per pixel, zero when the resolution of the slide is unknown
+	MppY                float64 //
+}
+
+func (i PyramidImage) TileIndex(tileX, tileY int) int {
+	numTilesHorizontal := i.ImageWidth / i.TileWidth
+	if i.ImageWidth%i.TileWidth > 0 {
+		numTilesHorizontal += 1
+	}
+	idx := tileY*numTilesHorizontal + tileX
+	return idx
+}
+
+// TileIndex returns the index of the tile at column tileX and row tileY of a level, checked against the tile counts:
+// the error wraps ErrLevelOutOfRange or ErrTileOutOfRange.
+func (p PyramidMetadata) TileIndex(levelIdx, tileX, tileY int) (int, error) {
+	if levelIdx < 0 || levelIdx >= len(p.Levels) {
+		return -1, fmt.Errorf("%w: %d of %d levels", ErrLevelOutOfRange, levelIdx, len(p.Levels))
+	}
+	level := p.Levels[levelIdx]
+	if tileX < 0 || tileX >= level.TileCountHorizontal || tileY < 0 || tileY >= level.TileCountVertical {
+		return -1, fmt.Errorf("%w: %d_%d of %dx%d tiles", ErrTileOutOfRange, tileX, tileY, level.TileCountHorizontal, level.TileCountVertical)
+	}
+	return level.TileIndex(tileX, tileY), nil
+}
+
+// BestLevel returns the level of the largest downsample not exceeding downsample, the level 0 when all exceed it.
+// Reading a region from this level and scaling it down gives the requested downsample without losing details.
+func (p PyramidMetadata) BestLevel(downsample float64) int {
+	best := 0
+	for i, level := range p.Levels {
+		if level.Downsample <= downsample*(1+1e-6) && level.Downsample >= p.Levels[best].Downsample {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,82 @@
+package slide
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // decoding of the JPEG tiles
+	_ "image/png"  // decoding of the decoded tiles, see getDecodedTile
+	"math"
+)
+
+// SetFillColor sets the colour of the pixels of the regions outside the level, transparent by default.
+func (r *SlideReader) SetFillColor(fill color.Color) {
+	r.fill = fill
+}
+
+// ReadRegion returns the region of width x height pixels of the level whose top left corner is at x, y
+// in the coordinates of the level 0, as OpenSlide does.
+// The tiles crossed by the region are decoded and composited, the pixels outside the level being filled
+// with the colour set by SetFillColor.
+func (r *SlideReader) ReadRegion(levelIdx, x, y, width, height int) (image.Image, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid region size %dx%d", width, height)
+	}
+	pyramid, err := pyramidMetadata(r.pyramid)
+	if err != nil {
+		return nil, err
+	}
+	if levelIdx < 0 || levelIdx >= len(pyramid.Levels) {
+		return nil, fmt.Errorf("%w: %d", ErrLevelOutOfRange, levelIdx)
+	}
+	level, level0 := pyramid.Levels[levelIdx], pyramid.Levels[0]
+	if level.ImageWidth == 0 || level.ImageHeight == 0 {
+		return nil, fmt.Errorf("empty level %d", levelIdx)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if r.fill != nil {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(r.fill), image.Point{}, draw.Src)
+	}
+	// the regions out of the level are filled before scaling their corner, which could overflow
+	scaledX := math.Floor(float64(x) * float64(level.ImageWidth) / float64(level0.ImageWidth))
+	scaledY := math.Floor(float64(y) * float64(level.ImageHeight) / float64(level0.ImageHeight))
+	if scaledX <= -float64(width) || scaledX >= float64(level.ImageWidth) ||
+		scaledY <= -float64(height) || scaledY >= float64(level.ImageHeight) {
+		return dst, nil
+	}
+
+	// region in the coordinates of the level
+	origin := image.Pt(x*level.ImageWidth/level0.ImageWidth, y*level.ImageHeight/level0.ImageHeight)
+	if x < 0 {
+		origin.X = -ceilDiv(-x*level.ImageWidth, level0.ImageWidth)
+	}
+	if y < 0 {
+		origin.Y = -ceilDiv(-y*level.ImageHeight, level0.ImageHeight)
+	}
+	region := image.Rectangle{Min: origin, Max: origin.Add(image.Pt(width, height))}
+	visible := region.Intersect(image.Rect(0, 0, level.ImageWidth, level.ImageHeight))
+	if visible.Empty() {
+		return dst, nil
+	}
+
+	for ty := visible.Min.Y / level.TileHeight; ty <= (visible.Max.Y-1)/level.TileHeight; ty++ {
+		for tx := visible.Min.X / level.TileWidth; tx <= (visible.Max.X-1)/level.TileWidth; tx++ {
+			data, err := r.GetTile(levelIdx, level.TileIndex(tx, ty))
+			if err != nil {
+				return nil, fmt.Errorf("ReadRegion: tile %d_%d: %w", tx, ty, err)
+			}
+			tile, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("ReadRegion: unable to decode tile %d_%d: %w", tx, ty, err)
+			}
+			tileOrigin := image.Pt(tx*level.TileWidth, ty*level.TileHeight)
+			target := tile.Bounds().Sub(tile.Bounds().Min).Add(tileOrigin).Intersect(visible)
+			source := target.Min.Sub(tileOrigin).Add(tile.Bounds().Min)
+			draw.Draw(dst, target.Sub(region.Min), tile, source, draw.Src)
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,56 @@
+package slide
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestReadRegion(t *testing.T) {
+	name := writeTiledJPEGTIFF(t, 2, 2, 64) // 128x128 pixels
+	reader := NewSlideReader()
+	if err := reader.OpenFile(name); err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	fill := color.RGBA{R: 0xff, A: 0xff}
+	reader.SetFillColor(fill)
+
+	tests := []struct {
+		name   string
+		x, y   int
+		filled []image.Point // pixels of the region expected to be filled
+		drawn  []image.Point // pixels of the region expected to be read from the tiles
+	}{
+		{name: "across the tiles", x: 50, y: 50, drawn: []image.Point{{0, 0}, {19, 19}}},
+		{name: "top left corner outside", x: -10, y: -10, filled: []image.Point{{0, 0}, {9, 19}}, drawn: []image.Point{{10, 10}}},
+		{name: "bottom right corner outside", x: 118, y: 118, filled: []image.Point{{10, 10}}, drawn: []image.Point{{9, 9}}},
+		{name: "outside the level", x: 200, y: 0, filled: []image.Point{{0, 0}, {19, 19}}},
+		{name: "huge x", x: math.MaxInt, y: 0, filled: []image.Point{{0, 0}}},
+		{name: "huge negative x", x: math.MinInt, y: 0, filled: []image.Point{{19, 19}}},
+		{name: "huge y", x: 0, y: math.MaxInt - 5, filled: []image.Point{{0, 0}}},
+		{name: "huge negative y", x: 0, y: math.MinInt + 5, filled: []image.Point{{19, 19}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := reader.ReadRegion(0, tt.x, tt.y, 20, 20)
+			if err != nil {
+				t.Fatalf("ReadRegion: %v", err)
+			}
+			if size := img.Bounds().Size(); size != image.Pt(20, 20) {
+				t.Fatalf("ReadRegion: %v pixels, want 20x20", size)
+			}
+			for _, p := range tt.filled {
+				if got := img.At(p.X, p.Y); got != fill {
+					t.Errorf("pixel %v = %v, want the fill colour", p, got)
+				}
+			}
+			for _, p := range tt.drawn {
+				if got := img.At(p.X, p.Y); got == fill {
+					t.Errorf("pixel %v is filled, want a pixel of the level", p)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package slide
+
+import (
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"log/slog"
+	"math"
+)
+
+// maxPlausibleMpp is the largest resolution read from the TIFF tags, in microns per pixel.
+// Larger values are the 72 or 96 dpi defaults of the writers (352.8 and 264.6 µm), not the resolution of a scan.
+const maxPlausibleMpp = 100
+
+// mppTolerance is the relative difference above which the resolution of the TIFF tags disagrees with the vendor one.
+const mppTolerance = 0.01
+
+// slideMpp returns the microns per pixel of the level 0, zero when unknown.
+// The resolution given by the vendor takes precedence over XResolution and YResolution,
+// which are often left to a default value by the scanners.
+func slideMpp(slide SlideMetadata) (float64, float64) {
+	var tiffX, tiffY float64
+	if len(slide.Directories) > 0 {
+		tiffX, tiffY = resolutionMpp(slide.Directories[0])
+	}
+	if slide.MppX <= 0 || slide.MppY <= 0 {
+		return tiffX, tiffY
+	}
+	if tiffX > 0 && (math.Abs(tiffX-slide.MppX) > mppTolerance*slide.MppX || math.Abs(tiffY-slide.MppY) > mppTolerance*slide.MppY) {
+		slog.Debug("TIFF resolution disagrees with the vendor, vendor one used", "vendor", slide.Vendor,
+			"mppX", slide.MppX, "mppY", slide.MppY, "tiffMppX", tiffX, "tiffMppY", tiffY)
+	}
+	return slide.MppX, slide.MppY
+}
+
+// resolutionMpp returns the microns per pixel given by the XResolution and YResolution of the directory,
+// in pixels per inch or per centimetre. Zero when they are missing, without unit or implausible, see maxPlausibleMpp.
+func resolutionMpp(directory model.TIFFDirectory) (float64, float64) {
+	var micrometres float64
+	switch directory.GetResolutionUnit() {
+	case tags.ResolutionUnitTypeInch:
+		micrometres = 25400
+	case tags.ResolutionUnitTypeCentimeter:
+		micrometres = 10000
+	default:
+		return 0, 0
+	}
+	xResolution, err := directory.GetFloatTag(tags.XResolution)
+	if err != nil || xResolution <= 0 {
+		return 0, 0
+	}
+	yResolution, err := directory.GetFloatTag(tags.YResolution)
+	if err != nil || yResolution <= 0 {
+		return 0, 0
+	}
+	mppX, mppY := micrometres/xResolution, micrometres/yResolution
+	if mppX > maxPlausibleMpp || mppY > maxPlausibleMpp {
+		slog.Debug("TIFF resolution implausible for a slide, discarded", "xResolution", xResolution,
+			"yResolution", yResolution, "mppX", mppX, "mppY", mppY)
+		return 0, 0
+	}
+	return mppX, mppY
+}
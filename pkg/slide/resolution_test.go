@@ -0,0 +1,45 @@
+package slide
+
+import (
+	"math"
+	"testing"
+
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+)
+
+func TestResolutionMpp(t *testing.T) {
+	tests := []struct {
+		name     string
+		unit     uint16 // ResolutionUnit, 0 when missing
+		x, y     model.Rational
+		wantMppX float64
+		wantMppY float64
+	}{
+		{"centimetre", 3, model.Rational{Numerator: 40000, Denominator: 1}, model.Rational{Numerator: 40000, Denominator: 1}, 0.25, 0.25},
+		{"inch", 2, model.Rational{Numerator: 101600, Denominator: 1}, model.Rational{Numerator: 50800, Denominator: 1}, 0.25, 0.5},
+		{"inch by default", 0, model.Rational{Numerator: 101600, Denominator: 1}, model.Rational{Numerator: 101600, Denominator: 1}, 0.25, 0.25},
+		{"no unit", 1, model.Rational{Numerator: 40000, Denominator: 1}, model.Rational{Numerator: 40000, Denominator: 1}, 0, 0},
+		{"72 dpi default", 2, model.Rational{Numerator: 72, Denominator: 1}, model.Rational{Numerator: 72, Denominator: 1}, 0, 0},
+		{"96 dpi default", 2, model.Rational{Numerator: 96, Denominator: 1}, model.Rational{Numerator: 96, Denominator: 1}, 0, 0},
+		{"one axis implausible", 3, model.Rational{Numerator: 40000, Denominator: 1}, model.Rational{Numerator: 1, Denominator: 1}, 0, 0},
+		{"100 µm", 3, model.Rational{Numerator: 100, Denominator: 1}, model.Rational{Numerator: 100, Denominator: 1}, 100, 100},
+		{"zero", 3, model.Rational{Numerator: 0, Denominator: 1}, model.Rational{Numerator: 40000, Denominator: 1}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directoryTags := map[tags.TagID]model.TIFFTag{
+				tags.XResolution: model.DataTag[model.Rational]{TagID: tags.XResolution, Values: []model.Rational{tt.x}},
+				tags.YResolution: model.DataTag[model.Rational]{TagID: tags.YResolution, Values: []model.Rational{tt.y}},
+			}
+			if tt.unit > 0 {
+				directoryTags[tags.ResolutionUnit] = model.DataTag[uint16]{TagID: tags.ResolutionUnit, Values: []uint16{tt.unit}}
+			}
+
+			mppX, mppY := resolutionMpp(model.NewTIFFDirectory(directoryTags))
+			if math.Abs(mppX-tt.wantMppX) > 1e-9 || math.Abs(mppY-tt.wantMppY) > 1e-9 {
+				t.Errorf("resolutionMpp() = %g, %g, want %g, %g", mppX, mppY, tt.wantMppX, tt.wantMppY)
+			}
+		})
+	}
+}
@@ -0,0 +1,720 @@
+package slide
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	jpegio "github.com/chennequin/fast-tiff-reader/pkg/jpeg"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff"
+	tiffModel "github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/image/tiff/lzw"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Errors of the tile reads, to be tested with errors.Is, the level, tile and compression ones being the errors of the tiff package.
+var (
+	ErrLevelOutOfRange        = tiff.ErrLevelOutOfRange
+	ErrPlaneOutOfRange        = errors.New("plane out of range") // series or plane of an OME-TIFF file
+	ErrRegionTooLarge         = errors.New("region too large")   // Deep Zoom tile read from too large a region of the levels
+	ErrTileOutOfRange         = tiff.ErrTileOutOfRange
+	ErrUnsupportedCompression = tiff.ErrUnsupportedCompression
+)
+
+// URLTimeout bounds the requests of the slides opened by OpenURL, so that a stalled server fails the read.
+const URLTimeout = 30 * time.Second
+
+// S3Timeout bounds the requests of the slides opened by OpenS3, so that a stalled endpoint fails the read.
+const S3Timeout = 30 * time.Second
+
+type SlideReader struct {
+	format  Format
+	pyramid SlideMetadata
+	reader  *tiff.FastTiffReader
+	fill    color.Color  // colour of the regions outside the levels, see ReadRegion
+	refs    atomic.Int64 // the owner and the holders, see Retain
+}
+
+func NewSlideReader() *SlideReader {
+	return &SlideReader{}
+}
+
+// OpenReaderAt creates a SlideReader reading the slide from an io.ReaderAt, see SlideReader.OpenReaderAt.
+func OpenReaderAt(readerAt io.ReaderAt, size int64) (*SlideReader, error) {
+	reader := NewSlideReader()
+	if err := reader.OpenReaderAt(readerAt, size); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (r *SlideReader) OpenFile(name string) error {
+	return r.Open(tiff.NewFileBinaryReader(), name)
+}
+
+// OpenMmap opens a local file mapped in memory, see tiff.MmapBinaryReader.
+func (r *SlideReader) OpenMmap(name string) error {
+	return r.Open(tiff.NewMmapBinaryReader(), name)
+}
+
+// OpenReaderAt opens a slide of the given size provided by any io.ReaderAt, which remains owned by the caller.
+func (r *SlideReader) OpenReaderAt(readerAt io.ReaderAt, size int64) error {
+	return r.Open(tiff.NewReaderAtBinaryReader(readerAt, size), "")
+}
+
+// OpenS3 opens a slide stored in an S3-compatible bucket, each request being bounded by S3Timeout.
+func (r *SlideReader) OpenS3(client *minio.Client, bucket, key string) error {
+	return r.OpenS3WithTimeout(client, bucket, key, S3Timeout)
+}
+
+// OpenS3WithTimeout opens a slide stored in an S3-compatible bucket, each request being bounded by timeout.
+func (r *SlideReader) OpenS3WithTimeout(client *minio.Client, bucket, key string, timeout time.Duration) error {
+	return r.Open(tiff.NewS3BinaryReader(client, bucket, timeout), key)
+}
+
+// OpenURL opens a slide published by a web server supporting HTTP Range requests, each request being bounded by URLTimeout.
+func (r *SlideReader) OpenURL(url string) error {
+	return r.OpenURLWithClient(&http.Client{Timeout: URLTimeout}, url)
+}
+
+// OpenURLWithClient opens a remote slide with the given client, i.e. one restricting the hosts it connects to.
+func (r *SlideReader) OpenURLWithClient(client *http.Client, url string) error {
+	return r.Open(tiff.NewHTTPBinaryReader(client), url)
+}
+
+// Open reads the slide named name through the given BinaryReader.
+func (r *SlideReader) Open(binaryReader tiff.BinaryReader, name string) error {
+	cacheBinaryReader := tiff.NewCacheBinaryReader(binaryReader)
+	tiffReader := tiff.NewFastTiffReader(cacheBinaryReader)
+
+	err := tiffReader.Open(name)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := tiffReader.ReadMetadata()
+	if err != nil {
+		tiffReader.Close()
+		return fmt.Errorf("unable to read Metadata: %w", err)
+	}
+
+	r.reader = tiffReader
+	r.format, r.pyramid, err = r.openFormat(NewProbe(name, metadata), metadata)
+	if err != nil {
+		tiffReader.Close()
+		return err
+	}
+
+	r.refs.Store(1) // the reference of the owner, released by Close
+	return nil
+}
+
+// TiffReader returns the reader of the TIFF file, for the formats registered outside of this package.
+func (r *SlideReader) TiffReader() *tiff.FastTiffReader {
+	return r.reader
+}
+
+// Close releases the reference of the owner of the reader: the file is closed once the holders
+// having retained the reader have released it as well.
+func (r *SlideReader) Close() {
+	r.Release()
+}
+
+// Retain keeps the reader open, and the tiles it returned valid (i.e. slices of a mapped file), until the matching
+// Release, even if the owner closes it meanwhile. It fails once the reader is closed.
+func (r *SlideReader) Retain() bool {
+	for {
+		refs := r.refs.Load()
+		if refs <= 0 {
+			return false
+		}
+		if r.refs.CompareAndSwap(refs, refs+1) {
+			return true
+		}
+	}
+}
+
+// Release drops a reference taken by Retain, closing the file with the last one.
+func (r *SlideReader) Release() {
+	if r.refs.Add(-1) == 0 {
+		r.reader.Close()
+	}
+}
+
+func (r *SlideReader) GetMetadata() (PyramidMetadata, error) {
+	return pyramidMetadata(r.pyramid)
+}
+
+// GetImages returns the images of a collection (Leica), empty when the slide holds a single image.
+func (r *SlideReader) GetImages() []SlideImage {
+	return r.pyramid.Images
+}
+
+// GetImageMetadata returns the pyramid of the image at index imageIdx of the collection, see GetImages.
+func (r *SlideReader) GetImageMetadata(imageIdx int) (PyramidMetadata, error) {
+	slideImage, err := r.pyramid.Image(imageIdx)
+	if err != nil {
+		return PyramidMetadata{}, err
+	}
+	return pyramidMetadata(slideImage)
+}
+
+// GetImageTile returns a tile of the image at index imageIdx of the collection, see GetImages.
+func (r *SlideReader) GetImageTile(imageIdx, levelIdx, tileIdx int) ([]byte, error) {
+	slideImage, err := r.pyramid.Image(imageIdx)
+	if err != nil {
+		return nil, err
+	}
+	return r.format.ReadTile(r, slideImage, levelIdx, tileIdx)
+}
+
+// GetSeries returns the images of an OME-TIFF file, empty for other slides.
+func (r *SlideReader) GetSeries() []Series {
+	return r.pyramid.Series
+}
+
+// GetPlaneMetadata returns the pyramid of a plane of the series at index seriesIdx, see GetSeries.
+func (r *SlideReader) GetPlaneMetadata(seriesIdx int, plane Plane) (PyramidMetadata, error) {
+	slidePlane, err := r.pyramid.Plane(seriesIdx, plane)
+	if err != nil {
+		return PyramidMetadata{}, err
+	}
+	return pyramidMetadata(slidePlane)
+}
+
+// GetPlaneTile returns a tile of an OME-TIFF file, see GetSeries.
+func (r *SlideReader) GetPlaneTile(address TileAddress) ([]byte, error) {
+	slidePlane, err := r.pyramid.Plane(address.Series, Plane{C: address.Channel, Z: address.Z, T: address.T})
+	if err != nil {
+		return nil, err
+	}
+	pyramid, err := pyramidMetadata(slidePlane)
+	if err != nil {
+		return nil, err
+	}
+	tileIdx, err := pyramid.TileIndex(address.Level, address.X, address.Y)
+	if err != nil {
+		return nil, err
+	}
+	return r.format.ReadTile(r, slidePlane, address.Level, tileIdx)
+}
+
+func pyramidMetadata(slide SlideMetadata) (PyramidMetadata, error) {
+	var pyramid PyramidMetadata
+	pyramid.Levels = make([]PyramidImage, 0)
+	mppX, mppY := slideMpp(slide)
+	for levelIdx, level := range slide.Directories {
+		var imageWidth, imageLength, tileWidth, tileLength int
+		if grid := slide.Restart(levelIdx); grid != nil {
+			imageWidth, imageLength, tileWidth, tileLength = grid.Width, grid.Height, grid.TileWidth, grid.TileHeight
+		} else {
+			imageTags, err := level.Tags(tags.ImageWidth, tags.ImageLength, tags.TileWidth, tags.TileLength)
+			if err != nil {
+				return pyramid, fmt.Errorf("missing required tags: %w", err)
+			}
+			imageWidth = int(imageTags[0].GetUintVal(0))
+			imageLength = int(imageTags[1].GetUintVal(0))
+			tileWidth = int(imageTags[2].GetUintVal(0))
+			tileLength = int(imageTags[3].GetUintVal(0))
+		}
+		if size := slide.Size(levelIdx); size.X > 0 && size.Y > 0 {
+			imageWidth, imageLength = min(imageWidth, size.X), min(imageLength, size.Y)
+		}
+
+		tileCountHorizontal := imageWidth / tileWidth
+		if imageWidth%tileWidth > 0 {
+			tileCountHorizontal += 1
+		}
+
+		tileCountVertical := imageLength / tileLength
+		if imageLength%tileLength > 0 {
+			tileCountVertical += 1
+		}
+
+		l := PyramidImage{
+			ImageWidth:          imageWidth,
+			ImageHeight:         imageLength,
+			TileWidth:           tileWidth,
+			TileHeight:          tileLength,
+			TileCountHorizontal: tileCountHorizontal,
+			TileCountVertical:   tileCountVertical,
+			Downsample:          1,
+			MppX:                mppX,
+			MppY:                mppY,
+		}
+		if levelIdx > 0 && imageWidth > 0 && imageLength > 0 {
+			// the smaller levels are derived from the level 0, their resolution tags being often copied from it
+			level0 := pyramid.Levels[0]
+			downsampleX := float64(level0.ImageWidth) / float64(imageWidth)
+			downsampleY := float64(level0.ImageHeight) / float64(imageLength)
+			l.Downsample = (downsampleX + downsampleY) / 2
+			l.MppX, l.MppY = mppX*downsampleX, mppY*downsampleY
+		}
+
+		pyramid.Levels = append(pyramid.Levels, l)
+	}
+	slog.Debug("Pyramid Metadata", "levels", len(pyramid.Levels), "metadata", pyramid)
+	return pyramid, nil
+}
+
+// GetExif returns the camera settings of the slide, read from the first directory referencing an EXIF directory,
+// the pyramid levels being searched before the extra images.
+func (r *SlideReader) GetExif() (tiffModel.Exif, error) {
+	directories := slices.Concat(r.format.Levels(r.pyramid), r.pyramid.ExtraImages)
+	for _, directory := range directories {
+		if _, err := directory.ExifIFD(); err == nil {
+			return tiffModel.NewExif(directory)
+		}
+	}
+	return tiffModel.Exif{}, tiffModel.NewTagNotFoundError(tags.ExifIFD)
+}
+
+// GetVendor returns the name of the format of the slide, i.e. aperio, generic for the TIFF files of no known vendor.
+func (r *SlideReader) GetVendor() string {
+	return r.pyramid.Vendor
+}
+
+// GetVendorProperties returns the properties parsed from the vendor description, keyed as written by the vendor.
+func (r *SlideReader) GetVendorProperties() map[string]string {
+	return r.format.Properties(r.pyramid)
+}
+
+// GetAssociatedImageNames returns the kinds of the associated images of the slide, i.e. thumbnail, label, macro.
+func (r *SlideReader) GetAssociatedImageNames() []string {
+	return r.format.AssociatedImages(r.pyramid)
+}
+
+// GetAssociatedImage returns the associated image of the given kind as JPEG.
+func (r *SlideReader) GetAssociatedImage(name string) ([]byte, error) {
+	if data, ok := r.pyramid.AssociatedData[name]; ok {
+		return data, nil
+	}
+	directory, err := r.pyramid.Associated(name)
+	if err != nil {
+		return nil, err
+	}
+	recompose := r.recomposeStripImage
+	if _, err := directory.GetTileWidth(); err == nil {
+		recompose = r.recomposeTiledImage
+	}
+	data, err := recompose(directory)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %s image: %w", name, err)
+	}
+	return data, nil
+}
+
+func (r *SlideReader) GetTile(levelIdx, tileIdx int) ([]byte, error) {
+	return r.format.ReadTile(r, r.pyramid, levelIdx, tileIdx)
+}
+
+func (r *SlideReader) getTile(slide SlideMetadata, levelIdx, tileIdx int) ([]byte, error) {
+	level, err := slide.Level(levelIdx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get level %d: %w", levelIdx, err)
+	}
+	tile, err := r.getLevelTile(level, tileIdx, slide.Size(levelIdx))
+	if err != nil {
+		if errors.Is(err, tiffModel.NewTagNotFoundError(tags.TileWidth)) || errors.Is(err, tiffModel.NewTagNotFoundError(tags.TileOffsets)) {
+			return r.recomposeStripImage(level)
+		}
+	}
+	return tile, err
+}
+
+func (r *SlideReader) GetExtraImage(levelIdx int) ([]byte, error) {
+	level, err := r.pyramid.Extra(levelIdx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get level %d: %w", levelIdx, err)
+	}
+	data, err := r.recomposeStripImage(level)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get level %d: %w", levelIdx, err)
+	}
+	return data, nil
+}
+
+// getLevelTile returns a tile of a tiled level: JPEG tiles are served as stored, the other ones are decoded.
+func (r *SlideReader) getLevelTile(level tiffModel.TIFFDirectory, tileIdx int, size image.Point) ([]byte, error) {
+	if compression, err := level.GetCompression(); err == nil && compression != tags.CompressionTypeJPEG {
+		return r.getDecodedTile(level, tileIdx, size)
+	}
+	return r.getRawTileJPEG(level, tileIdx, size)
+}
+
+func (r *SlideReader) getRawTileJPEG(level tiffModel.TIFFDirectory, tileIdx int, size image.Point) ([]byte, error) {
+	tiffTileIdx, expectedWidth, expectedHeight, err := r.calculateTileWidthHeight(level, tileIdx, size)
+	if err != nil {
+		return nil, fmt.Errorf("getRawTileJPEG: unable to calculate expected tile size: %w", err)
+	}
+
+	data, err := r.reader.GetTileData(level, tiffTileIdx)
+	if err != nil {
+		return nil, fmt.Errorf("getRawTileJPEG: unable to obtain tile data: %w", err)
+	}
+
+	var tileWidth, tileHeight int
+	var encoded []byte
+	var jpegTablesErr, iccProfileErr error
+	var jpegTables, iccProfile []byte
+
+	jpegTables, jpegTablesErr = level.GetJPEGTables()
+	iccProfile, iccProfileErr = r.retrieveIccProfile(level)
+
+	if jpegTablesErr == nil || iccProfileErr == nil {
+		tileWidth, tileHeight, encoded, err = jpegio.MergeSegments(data, jpegTables, iccProfile)
+		if err != nil {
+			return nil, fmt.Errorf("getRawTileJPEG: unable to merge JPEG segments: %w", err)
+		}
+	} else {
+		// JPEGTables and ICC Profile are not present in Metadata
+		tileWidth, tileHeight, err = jpegio.DecodeSOF(data)
+		if err != nil {
+			return nil, fmt.Errorf("getRawTileJPEG: unable to decode JPEG segment: %w", err)
+		}
+		encoded = data
+	}
+
+	if tileWidth != expectedWidth || tileHeight != expectedHeight {
+		encoded, err = r.cropImageJPEG(expectedWidth, expectedHeight, encoded)
+	}
+
+	return encoded, err
+}
+
+// getStitchedTile composes a tile of the seamless level 0 from the overlapping scan tiles it covers,
+// the scan tiles being drawn in the order of the TIFF grid.
+func (r *SlideReader) getStitchedTile(level tiffModel.TIFFDirectory, grid *StitchGrid, tileIdx int) ([]byte, error) {
+	columns := ceilDiv(grid.Width, grid.TileWidth)
+	x, y := tileIdx%columns, tileIdx/columns
+	bounds := image.Rect(x*grid.TileWidth, y*grid.TileHeight, (x+1)*grid.TileWidth, (y+1)*grid.TileHeight).
+		Intersect(image.Rect(0, 0, grid.Width, grid.Height))
+	if tileIdx < 0 || bounds.Empty() {
+		return nil, fmt.Errorf("getStitchedTile: %w: %d", ErrTileOutOfRange, tileIdx)
+	}
+
+	tile := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for row, top := range grid.RowPositions {
+		for col, left := range grid.ColumnPositions {
+			scanTile := image.Rect(left, top, left+grid.TileWidth, top+grid.TileHeight)
+			if !scanTile.Overlaps(bounds) {
+				continue
+			}
+			data, err := r.getRawTileJPEG(level, row*grid.Columns+col, image.Point{})
+			if err != nil {
+				return nil, fmt.Errorf("getStitchedTile: %w", err)
+			}
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("getStitchedTile: unable to decode JPEG: %w", err)
+			}
+			draw.Draw(tile, scanTile.Sub(bounds.Min), img, image.Point{}, draw.Src)
+		}
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0))
+	if err := jpeg.Encode(buf, tile, nil); err != nil {
+		return nil, fmt.Errorf("getStitchedTile: unable to encode JPEG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// recomposeTiledImage assembles all the tiles of a tiled directory into a single JPEG image, i.e. Leica macro images.
+func (r *SlideReader) recomposeTiledImage(level tiffModel.TIFFDirectory) ([]byte, error) {
+	imageTags, err := level.Tags(tags.ImageWidth, tags.ImageLength, tags.TileWidth, tags.TileLength)
+	if err != nil {
+		return nil, fmt.Errorf("recomposeTiledImage: missing required tags: %w", err)
+	}
+	widthImage := int(imageTags[0].GetUintVal(0))
+	heightImage := int(imageTags[1].GetUintVal(0))
+	tileWidth := int(imageTags[2].GetUintVal(0))
+	tileHeight := int(imageTags[3].GetUintVal(0))
+	if tileWidth == 0 || tileHeight == 0 {
+		return nil, fmt.Errorf("recomposeTiledImage: invalid tile size %dx%d", tileWidth, tileHeight)
+	}
+
+	finalImage := image.NewRGBA(image.Rect(0, 0, widthImage, heightImage))
+	columns, rows := ceilDiv(widthImage, tileWidth), ceilDiv(heightImage, tileHeight)
+	for tileIdx := range columns * rows {
+		data, err := r.getLevelTile(level, tileIdx, image.Point{})
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("recomposeTiledImage: unable to decode tile: %w", err)
+		}
+		origin := image.Pt(tileIdx%columns*tileWidth, tileIdx/columns*tileHeight)
+		draw.Draw(finalImage, img.Bounds().Add(origin), img, image.Point{}, draw.Src)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0))
+	if err = jpeg.Encode(buf, finalImage, nil); err != nil {
+		return nil, fmt.Errorf("recomposeTiledImage: unable to encode JPEG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *SlideReader) recomposeStripImage(level tiffModel.TIFFDirectory) ([]byte, error) {
+	stripCount, err := level.GetStripCount()
+	if err != nil {
+		return nil, err
+	}
+	widthImage, err := level.GetImageWidth()
+	if err != nil {
+		return nil, err
+	}
+	heightImage, err := level.GetImageHeight()
+	if err != nil {
+		return nil, err
+	}
+	rowsPerStrip, err := level.GetRowsPerStrip()
+	if err != nil {
+		return nil, err
+	}
+	compression, err := level.GetCompression()
+	if err != nil {
+		return nil, err
+	}
+	finalImage := image.NewRGBA(image.Rect(0, 0, widthImage, heightImage))
+	for stripIdx := range stripCount {
+		data, err := r.getRawStrip(level, stripIdx)
+		if err != nil {
+			return nil, err
+		}
+
+		switch compression {
+		case tags.CompressionTypeJPEG:
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("recomposeStripImage: unable to decode JPEG: %w", err)
+			}
+			rect := image.Rect(0, rowsPerStrip*stripIdx, 0+widthImage, rowsPerStrip*stripIdx+rowsPerStrip)
+			draw.Draw(finalImage, rect, img, image.Point{}, draw.Over)
+
+		case tags.CompressionTypeLZW:
+			lzwReader := lzw.NewReader(bytes.NewReader(data), lzw.MSB, 8)
+
+			var decompressedData []byte
+			buf := make([]byte, 4096)
+			for {
+				n, err := lzwReader.Read(buf)
+				if err != nil {
+					if err.Error() != "EOF" {
+						return nil, fmt.Errorf("recomposeStripImage: unable to read lzw: %w", err)
+					}
+					break
+				}
+				decompressedData = append(decompressedData, buf[:n]...)
+			}
+			err = lzwReader.Close()
+			if err != nil {
+				return nil, fmt.Errorf("recomposeStripImage: unable to close lzw reader: %w", err)
+			}
+
+			photometricInterpretation, err := level.GetPhotometricInterpretation()
+			if err != nil {
+				return nil, err
+			}
+
+			predictor, err := level.GetPredictor()
+			if err != nil {
+				return nil, err
+			}
+
+			switch photometricInterpretation {
+			case tags.PhotometricInterpretationTypeRGB:
+				img := image.NewRGBA(image.Rect(0, 0, widthImage, rowsPerStrip))
+				for y := 0; y < rowsPerStrip; y++ {
+					var previousRed, previousGreen, previousBlue byte
+					for x := 0; x < widthImage; x++ {
+						if index := (y*widthImage + x) * 3; index < len(decompressedData) {
+							var red, green, blue byte
+							if predictor == tags.PredictorTypeHorizontalDifferencing {
+								red = previousRed + decompressedData[index]
+								green = previousGreen + decompressedData[index+1]
+								blue = previousBlue + decompressedData[index+2]
+							} else {
+								red = decompressedData[index]
+								green = decompressedData[index+1]
+								blue = decompressedData[index+2]
+							}
+							c := color.RGBA{
+								R: red,
+								G: green,
+								B: blue,
+								A: 255,
+							}
+							img.Set(x, y, c)
+
+							previousRed = red
+							previousGreen = green
+							previousBlue = blue
+						}
+					}
+				}
+				rect := image.Rect(0, rowsPerStrip*stripIdx, 0+widthImage, rowsPerStrip*stripIdx+rowsPerStrip)
+				draw.Draw(finalImage, rect, img, image.Point{}, draw.Over)
+
+			case tags.PhotometricInterpretationTypeYCbCr:
+				img := image.NewYCbCr(image.Rect(0, 0, widthImage, rowsPerStrip), image.YCbCrSubsampleRatio422)
+
+				copy(img.Y, decompressedData[:len(img.Y)])
+				copy(img.Cb, decompressedData[len(img.Y):len(img.Y)+len(img.Cb)])
+				copy(img.Cr, decompressedData[len(img.Y)+len(img.Cb):])
+
+				rect := image.Rect(0, rowsPerStrip*stripIdx, 0+widthImage, rowsPerStrip*stripIdx+rowsPerStrip)
+				draw.Draw(finalImage, rect, img, image.Point{}, draw.Over)
+
+				return nil, fmt.Errorf("recomposeStripImage: unsupported PhotometricInterpretation type: %v", compression)
+			}
+
+		default:
+			return nil, fmt.Errorf("recomposeStripImage: %w: %v", ErrUnsupportedCompression, compression)
+		}
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0))
+	if err = jpeg.Encode(buf, finalImage, nil); err != nil {
+		return nil, fmt.Errorf("recomposeStripImage: unable to encode JPEG: %w", err)
+	}
+	return buf.Bytes(), err
+}
+
+func (r *SlideReader) getRawStrip(level tiffModel.TIFFDirectory, stripIdx int) ([]byte, error) {
+	compression, err := level.GetCompression()
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	switch compression {
+	case tags.CompressionTypeJPEG:
+		data, err = r.getRawStripJPEG(level, stripIdx)
+	case tags.CompressionTypeLZW:
+		data, err = r.getRawStripLZW(level, stripIdx)
+	default:
+		return nil, fmt.Errorf("getRawStrip: %w: %v", ErrUnsupportedCompression, compression)
+	}
+	return data, err
+}
+
+func (r *SlideReader) getRawStripJPEG(level tiffModel.TIFFDirectory, stripIdx int) ([]byte, error) {
+	data, err := r.reader.GetStripData(level, stripIdx)
+	if err != nil {
+		return nil, fmt.Errorf("getRawStripJPEG: unable to obtain strip data: %w", err)
+	}
+
+	var jpegTablesErr, iccProfileErr error
+	var jpegTables, iccProfile []byte
+
+	jpegTables, jpegTablesErr = level.GetJPEGTables()
+	iccProfile, iccProfileErr = r.retrieveIccProfile(level)
+
+	if jpegTablesErr == nil || iccProfileErr == nil {
+		_, _, data, err = jpegio.MergeSegments(data, jpegTables, iccProfile)
+		if err != nil {
+			return nil, fmt.Errorf("getRawStripJPEG: unable to merge JPEG segments: %w", err)
+		}
+	}
+
+	// no crop operation to do in strips
+	return data, err
+}
+
+func (r *SlideReader) getRawStripLZW(level tiffModel.TIFFDirectory, stripIdx int) ([]byte, error) {
+	data, err := r.reader.GetStripData(level, stripIdx)
+	if err != nil {
+		return nil, fmt.Errorf("getRawStripLZW: unable to obtain strip data: %w", err)
+	}
+	return data, err
+}
+
+func (r *SlideReader) retrieveIccProfile(level tiffModel.TIFFDirectory) ([]byte, error) {
+	iccProfile, err := level.GetIccProfile() // ICC profile at this level
+	if err != nil {
+		// get the ICC profile from the main image
+		for i := range len(r.pyramid.Directories) {
+			l, err := r.pyramid.Level(i)
+			if err != nil {
+				return nil, err
+			}
+			iccProfile, err = l.GetIccProfile()
+			if err == nil {
+				return iccProfile, nil
+			}
+		}
+	}
+	return iccProfile, nil
+}
+
+func (r *SlideReader) cropImageJPEG(expectedWidth, expectedHeight int, tileData []byte) ([]byte, error) {
+
+	img, err := jpeg.Decode(bytes.NewReader(tileData))
+	if err != nil {
+		return nil, fmt.Errorf("cropImageJPEG: unable to decode JPEG: %w", err)
+	}
+
+	cropRect := image.Rect(0, 0, expectedWidth, expectedHeight)
+	croppedImg, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, fmt.Errorf("cropImageJPEG: image does not support cropping")
+	}
+
+	cropped := croppedImg.SubImage(cropRect)
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(tileData)))
+	if err = jpeg.Encode(buf, cropped, nil); err != nil {
+		return nil, fmt.Errorf("unable to encode JPEG: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// calculateTileWidthHeight returns the index of the tile in the TIFF grid of the level and its visible size.
+// size holds the true dimensions of the level when its tiled extents are padded (Philips), zero otherwise.
+func (r *SlideReader) calculateTileWidthHeight(level tiffModel.TIFFDirectory, tileIdx int, size image.Point) (int, int, int, error) {
+	imageTags, err := level.Tags(tags.ImageWidth, tags.ImageLength, tags.TileWidth, tags.TileLength)
+	if err != nil {
+		return -1, -1, -1, fmt.Errorf("missing required tags: %w", err)
+	}
+
+	imageWidth := int(imageTags[0].GetUintVal(0))
+	imageLength := int(imageTags[1].GetUintVal(0))
+	tileWidth := int(imageTags[2].GetUintVal(0))
+	tileLength := int(imageTags[3].GetUintVal(0))
+	if tileWidth == 0 || tileLength == 0 {
+		return -1, -1, -1, fmt.Errorf("invalid tile size %dx%d", tileWidth, tileLength)
+	}
+
+	tiffColumns := ceilDiv(imageWidth, tileWidth)
+	if size.X > 0 && size.Y > 0 {
+		imageWidth, imageLength = min(imageWidth, size.X), min(imageLength, size.Y)
+	}
+	columns := ceilDiv(imageWidth, tileWidth)
+	if tileIdx < 0 || tileIdx >= columns*ceilDiv(imageLength, tileLength) {
+		return -1, -1, -1, fmt.Errorf("%w: tileIdx %d", ErrTileOutOfRange, tileIdx)
+	}
+	x, y := tileIdx%columns, tileIdx/columns
+
+	actualWidth := min(tileWidth, imageWidth-x*tileWidth)
+	actualHeight := min(tileLength, imageLength-y*tileLength)
+
+	return y*tiffColumns + x, actualWidth, actualHeight, nil
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,133 @@
+package slide
+
+import (
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"image"
+	"slices"
+)
+
+// Kinds of the associated images.
+const (
+	AssociatedThumbnail   = "thumbnail"
+	AssociatedLabel       = "label"
+	AssociatedMacro       = "macro"
+	AssociatedProbability = "probability"
+)
+
+type SlideMetadata struct {
+	Directories model.TIFFMetadata // contains the pyramid Metadata
+	ExtraImages model.TIFFMetadata // contains a few optional extra images
+	ExtraNames  []string           // kind of each extra image (thumbnail, label, macro), empty when unknown
+	Vendor      string             // name of the format which opened the slide, see Format
+	Properties  map[string]string  // properties parsed from the vendor description, keyed as written by the vendor
+	Sizes       []image.Point      // true dimensions of the levels when their tiled extents are padded, empty otherwise
+
+	MppX           float64         // microns per pixel of the level 0 given by the vendor, zero when unknown
+	MppY           float64         //
+	ObjectivePower float64         // magnification of the objective given by the vendor, zero when unknown
+	Bounds         image.Rectangle // area of the level 0 holding the scanned tissue (Leica), empty when unknown
+
+	AssociatedData map[string][]byte // associated images embedded as JPEG in the vendor description
+	Images         []SlideImage      // images of a collection (Leica), the default pyramid being one of them
+	Stitch         *StitchGrid       // positions of the overlapping scan tiles of the level 0 (Ventana), nil otherwise
+	Restarts       []*RestartGrid    // virtual tiles of the levels stored as a single JPEG strip (Hamamatsu), empty otherwise
+	Series         []Series          // images of an OME-TIFF file, the default pyramid being the first plane of one of them
+}
+
+// SlideImage is one of the images of a collection, with its own pyramid and its position on the glass slide.
+// The offsets and sizes are in nanometres.
+type SlideImage struct {
+	Name        string             `json:"name"`
+	UUID        string             `json:"uuid"`
+	Macro       bool               `json:"macro"`
+	OffsetX     int64              `json:"offsetX"`
+	OffsetY     int64              `json:"offsetY"`
+	Width       int64              `json:"width"`
+	Height      int64              `json:"height"`
+	Directories model.TIFFMetadata `json:"-"` // levels of the image, from the largest to the smallest
+}
+
+func (t SlideMetadata) Level(level int) (model.TIFFDirectory, error) {
+	if level < 0 || level >= len(t.Directories) {
+		return model.TIFFDirectory{}, fmt.Errorf("%w: %d", ErrLevelOutOfRange, level)
+	}
+	return t.Directories[level], nil
+}
+
+func (t SlideMetadata) Extra(idx int) (model.TIFFDirectory, error) {
+	if idx >= len(t.ExtraImages) {
+		return model.TIFFDirectory{}, fmt.Errorf("directory index out of range: %d", idx)
+	}
+	return t.ExtraImages[idx], nil
+}
+
+// Image returns the pyramid of the image at index idx of the collection.
+func (t SlideMetadata) Image(idx int) (SlideMetadata, error) {
+	if idx < 0 || idx >= len(t.Images) {
+		return SlideMetadata{}, fmt.Errorf("image index out of range: %d", idx)
+	}
+	slideImage := SlideMetadata{Directories: t.Images[idx].Directories}
+	leicaCalibrate(&slideImage, t.Images[idx])
+	return slideImage, nil
+}
+
+// Plane returns the pyramid of a plane of the series at index idx of an OME-TIFF file.
+func (t SlideMetadata) Plane(idx int, plane Plane) (SlideMetadata, error) {
+	if idx < 0 || idx >= len(t.Series) {
+		return SlideMetadata{}, fmt.Errorf("%w: series %d", ErrPlaneOutOfRange, idx)
+	}
+	series := t.Series[idx]
+	levels, err := series.Levels(plane)
+	if err != nil {
+		return SlideMetadata{}, err
+	}
+	return SlideMetadata{
+		Directories: levels,
+		MppX:        series.PhysicalSizeX.Micrometres(),
+		MppY:        series.PhysicalSizeY.Micrometres(),
+	}, nil
+}
+
+// Size returns the true dimensions of the level, zero when those are given by the tags of the level.
+func (t SlideMetadata) Size(level int) image.Point {
+	if level < 0 || level >= len(t.Sizes) {
+		return image.Point{}
+	}
+	return t.Sizes[level]
+}
+
+// Restart returns the virtual tiles of the level, nil when the level is tiled.
+func (t SlideMetadata) Restart(level int) *RestartGrid {
+	if level < 0 || level >= len(t.Restarts) {
+		return nil
+	}
+	return t.Restarts[level]
+}
+
+// Associated returns the first extra image of the given kind.
+func (t SlideMetadata) Associated(name string) (model.TIFFDirectory, error) {
+	for i, n := range t.ExtraNames {
+		if n == name && i < len(t.ExtraImages) {
+			return t.ExtraImages[i], nil
+		}
+	}
+	return model.TIFFDirectory{}, fmt.Errorf("associated image not found: %s", name)
+}
+
+// AssociatedNames returns the kinds of the classified extra images and of the embedded images.
+func (t SlideMetadata) AssociatedNames() []string {
+	var names []string
+	for _, n := range t.ExtraNames {
+		if n != "" && !slices.Contains(names, n) {
+			names = append(names, n)
+		}
+	}
+	for n := range t.AssociatedData {
+		if !slices.Contains(names, n) {
+			names = append(names, n)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
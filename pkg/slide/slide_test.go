@@ -0,0 +1,196 @@
+package slide
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+)
+
+// tiffEntry is an entry of the directory written by writeTiledJPEGTIFF, SHORT (3) or LONG (4) values.
+type tiffEntry struct {
+	tagID  tags.TagID
+	typ    uint16
+	values []uint32
+}
+
+func (e tiffEntry) encode() []byte {
+	var data []byte
+	for _, v := range e.values {
+		if e.typ == 3 {
+			data = binary.LittleEndian.AppendUint16(data, uint16(v))
+		} else {
+			data = binary.LittleEndian.AppendUint32(data, v)
+		}
+	}
+	return data
+}
+
+// writeTiledJPEGTIFF writes a little-endian TIFF of a single level of JPEG tiles, each tile holding its own tables.
+func writeTiledJPEGTIFF(tb testing.TB, columns, rows, tileSize int) string {
+	tb.Helper()
+	var tiles [][]byte
+	for i := range columns * rows {
+		img := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+		for y := range tileSize {
+			for x := range tileSize {
+				img.Set(x, y, color.RGBA{R: uint8(x + i), G: uint8(y), B: uint8(x ^ y), A: 0xff})
+			}
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			tb.Fatal(err)
+		}
+		tiles = append(tiles, buf.Bytes())
+	}
+
+	entries := []tiffEntry{
+		{tags.ImageWidth, 4, []uint32{uint32(columns * tileSize)}},
+		{tags.ImageLength, 4, []uint32{uint32(rows * tileSize)}},
+		{tags.BitsPerSample, 3, []uint32{8, 8, 8}},
+		{tags.Compression, 3, []uint32{uint32(tags.CompressionTypeJPEG)}},
+		{tags.PhotometricInterpretation, 3, []uint32{6}}, // YCbCr
+		{tags.SamplesPerPixel, 3, []uint32{3}},
+		{tags.TileWidth, 3, []uint32{uint32(tileSize)}},
+		{tags.TileLength, 3, []uint32{uint32(tileSize)}},
+		{tags.TileOffsets, 4, make([]uint32, len(tiles))},
+		{tags.TileByteCounts, 4, make([]uint32, len(tiles))},
+	}
+
+	// header, directory, then the values stored outside the entries and the tiles
+	valuesOffset := 8 + 2 + len(entries)*12 + 4
+	var values []byte
+	offset := uint32(valuesOffset)
+	for i, tile := range tiles {
+		entries[9].values[i] = uint32(len(tile))
+	}
+	for _, e := range entries {
+		if size := len(e.encode()); size > 4 {
+			offset += uint32(size)
+		}
+	}
+	for i, tile := range tiles {
+		entries[8].values[i] = offset
+		offset += uint32(len(tile))
+	}
+
+	le := binary.LittleEndian
+	data := le.AppendUint32([]byte{'I', 'I', 0x2a, 0x00}, 8)
+	data = le.AppendUint16(data, uint16(len(entries)))
+	for _, e := range entries {
+		data = le.AppendUint16(data, uint16(e.tagID))
+		data = le.AppendUint16(data, e.typ)
+		data = le.AppendUint32(data, uint32(len(e.values)))
+		encoded := e.encode()
+		if len(encoded) <= 4 {
+			data = append(data, append(encoded, make([]byte, 4-len(encoded))...)...)
+			continue
+		}
+		data = le.AppendUint32(data, uint32(valuesOffset+len(values)))
+		values = append(values, encoded...)
+	}
+	data = le.AppendUint32(data, 0)
+	data = append(data, values...)
+	for _, tile := range tiles {
+		data = append(data, tile...)
+	}
+
+	name := filepath.Join(tb.TempDir(), "slide.tiff")
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return name
+}
+
+// BenchmarkSlideReaderGetTile reads the tiles of a single slide from concurrent goroutines,
+// the throughput being expected to scale with GOMAXPROCS.
+//
+//	go test ./pkg/slide -run '^$' -bench GetTile -cpu 1,2,4,8
+func BenchmarkSlideReaderGetTile(b *testing.B) {
+	const columns, rows = 8, 8
+	name := writeTiledJPEGTIFF(b, columns, rows, 256)
+
+	benchmarks := []struct {
+		name string
+		open func(*SlideReader, string) error
+	}{
+		{"pread", (*SlideReader).OpenFile},
+		{"mmap", (*SlideReader).OpenMmap},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			reader := NewSlideReader()
+			if err := bm.open(reader, name); err != nil {
+				b.Fatal(err)
+			}
+			defer reader.Close()
+			tile, err := reader.GetTile(0, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if config, err := jpeg.DecodeConfig(bytes.NewReader(tile)); err != nil || config.Width != 256 {
+				b.Fatalf("tile 0 is not a 256 pixels JPEG: %v", err)
+			}
+
+			var next atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					tileIdx := int(next.Add(1) % (columns * rows))
+					if _, err := reader.GetTile(0, tileIdx); err != nil {
+						b.Errorf("tile %d: %v", tileIdx, err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// TestSlideReaderRetain closes a mapped slide while a holder still writes one of its tiles, as the eviction of
+// a slide from the cache of the server does.
+func TestSlideReaderRetain(t *testing.T) {
+	name := writeTiledJPEGTIFF(t, 2, 2, 64)
+	tests := []struct {
+		name string
+		open func(*SlideReader, string) error
+	}{
+		{"pread", (*SlideReader).OpenFile},
+		{"mmap", (*SlideReader).OpenMmap},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewSlideReader()
+			if err := tt.open(reader, name); err != nil {
+				t.Fatal(err)
+			}
+			if !reader.Retain() {
+				t.Fatal("Retain of an open reader failed")
+			}
+			tile, err := reader.GetTile(0, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reader.Close()
+			if config, err := jpeg.DecodeConfig(bytes.NewReader(tile)); err != nil || config.Width != 64 {
+				t.Fatalf("tile retained after Close is not a 64 pixels JPEG: %v", err)
+			}
+			if _, err := reader.GetTile(0, 2); err != nil {
+				t.Fatalf("GetTile of a retained reader: %v", err)
+			}
+
+			reader.Release()
+			if reader.Retain() {
+				t.Fatal("Retain of a closed reader succeeded")
+			}
+		})
+	}
+}
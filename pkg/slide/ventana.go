@@ -0,0 +1,355 @@
+package slide
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"image"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	VendorVentana = "ventana"
+
+	ventanaLevelPrefix = "level="
+)
+
+// StitchGrid locates the overlapping scan tiles of a Ventana level 0 in the seamless stitched image.
+type StitchGrid struct {
+	Columns         int   // columns of scan tiles
+	Rows            int   // rows of scan tiles
+	TileWidth       int   // size of the scan tiles
+	TileHeight      int   //
+	ColumnPositions []int // left edge of each column of scan tiles in the stitched image
+	RowPositions    []int // top edge of each row of scan tiles in the stitched image
+	Width           int   // size of the stitched image
+	Height          int   //
+}
+
+// ventanaAOI is an area of interest of the scan, whose tiles are described in the XMP of the level 0, i.e.
+//
+//	<EncodeInfo Ver="2.0">
+//	  <SlideStitchInfo>
+//	    <ImageInfo AOIScanned="1" AOIIndex="0" NumRows="34" NumCols="38" ...>
+//	      <TileJointInfo FlagJoined="1" Direction="RIGHT" Tile1="1" Tile2="2" OverlapX="184" OverlapY="3"/>
+//	      ...
+type ventanaAOI struct {
+	NumRows int
+	NumCols int
+	Joints  []ventanaJoint
+}
+
+type ventanaJoint struct {
+	Joined   bool
+	Tile1    int
+	Tile2    int
+	OverlapX int
+	OverlapY int
+}
+
+func init() {
+	RegisterFormat(ventanaFormat{})
+}
+
+// ventanaFormat reads the BIF files of the Ventana (Roche) iScan scanners.
+type ventanaFormat struct {
+	BaseFormat
+}
+
+func (ventanaFormat) Name() string {
+	return VendorVentana
+}
+
+// Detect recognises a Ventana BIF file from the iScan element of the XMP of its directories.
+func (ventanaFormat) Detect(probe Probe) int {
+	score := ScoreNone
+	for _, directory := range probe.Metadata {
+		if tag, err := directory.LoadedTag(tags.XMP); err == nil && bytes.Contains(tag.AsBytes(), []byte("<iScan")) {
+			score += ScoreDescription
+			break
+		}
+	}
+	if probe.HasExtension(".bif") {
+		score += ScoreExtension
+	}
+	return score
+}
+
+func (ventanaFormat) Open(_ *SlideReader, metadata model.TIFFMetadata) (SlideMetadata, error) {
+	return partitionVentana(metadata)
+}
+
+// ReadTile stitches the overlapping scan tiles of the level 0 into a seamless grid.
+func (f ventanaFormat) ReadTile(r *SlideReader, slide SlideMetadata, levelIdx, tileIdx int) ([]byte, error) {
+	if levelIdx != 0 || slide.Stitch == nil {
+		return f.BaseFormat.ReadTile(r, slide, levelIdx, tileIdx)
+	}
+	level, err := slide.Level(levelIdx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get level %d: %w", levelIdx, err)
+	}
+	return r.getStitchedTile(level, slide.Stitch, tileIdx)
+}
+
+// partitionVentana classifies the directories of a Ventana BIF file from their ImageDescription:
+// "level=N mag=40 quality=90" for the pyramid, "Label Image", "Probability Image" and "Thumbnail" for the others.
+// The overlaps of the level 0 scan tiles are read from the XMP, to stitch them into a seamless grid.
+func partitionVentana(metadata model.TIFFMetadata) (SlideMetadata, error) {
+	slide := SlideMetadata{
+		Vendor:     VendorVentana,
+		Properties: make(map[string]string),
+	}
+
+	levels := make(map[int]model.TIFFDirectory)
+	for _, directory := range metadata {
+		description, _ := directory.GetStringTag(tags.ImageDescription)
+		if level, ok := ventanaLevel(description); ok {
+			levels[level] = directory
+			continue
+		}
+		slide.ExtraImages = append(slide.ExtraImages, directory)
+		slide.ExtraNames = append(slide.ExtraNames, ventanaAssociatedName(description))
+	}
+	if len(levels) == 0 {
+		return SlideMetadata{}, errors.New("ventana slide without pyramid levels")
+	}
+	levelKeys := mapsKeys(levels)
+	slices.Sort(levelKeys)
+	for _, level := range levelKeys {
+		slide.Directories = append(slide.Directories, levels[level])
+	}
+
+	var aois []ventanaAOI
+	for _, directory := range metadata {
+		tag, err := directory.LoadedTag(tags.XMP)
+		if errors.Is(err, model.NewTagNotFoundError(tags.XMP)) {
+			continue
+		}
+		if err != nil {
+			return SlideMetadata{}, fmt.Errorf("unable to read Ventana XMP: %w", err)
+		}
+		scan, directoryAOIs, err := parseVentanaXMP(tag.AsBytes())
+		if err != nil {
+			slog.Warn("Unable to parse Ventana XMP, ignored", "error", err)
+			continue
+		}
+		for k, v := range scan {
+			slide.Properties[k] = v
+		}
+		aois = append(aois, directoryAOIs...)
+	}
+	mpp := parsePositiveFloat(slide.Properties["ScanRes"])
+	slide.MppX, slide.MppY = mpp, mpp
+	slide.ObjectivePower = parsePositiveFloat(slide.Properties["Magnification"])
+
+	switch len(aois) {
+	case 0:
+	case 1:
+		grid, err := newStitchGrid(slide.Directories[0], aois[0])
+		if err != nil {
+			slog.Warn("Unable to stitch Ventana level 0, raw scan tiles served", "error", err)
+			break
+		}
+		slide.Stitch = grid
+		slide.Sizes = []image.Point{{X: grid.Width, Y: grid.Height}}
+	default:
+		slog.Warn("Ventana slide with several areas of interest, raw scan tiles served", "aois", len(aois))
+	}
+	return slide, nil
+}
+
+// ventanaLevel parses the level of a pyramid directory from its ImageDescription, i.e. "level=0 mag=40 quality=90".
+func ventanaLevel(description string) (int, bool) {
+	field, _, _ := strings.Cut(description, " ")
+	value, ok := strings.CutPrefix(field, ventanaLevelPrefix)
+	if !ok {
+		return 0, false
+	}
+	level, err := strconv.Atoi(value)
+	return level, err == nil && level >= 0
+}
+
+// ventanaAssociatedName returns the kind of a non-pyramid directory, named by its ImageDescription.
+func ventanaAssociatedName(description string) string {
+	switch description = strings.ToLower(strings.ReplaceAll(description, "_", " ")); {
+	case strings.HasPrefix(description, "label image"):
+		return AssociatedLabel
+	case strings.HasPrefix(description, "probability image"):
+		return AssociatedProbability
+	case strings.HasPrefix(description, "thumbnail"):
+		return AssociatedThumbnail
+	}
+	return ""
+}
+
+// parseVentanaXMP returns the attributes of the iScan element and the areas of interest of the EncodeInfo element.
+func parseVentanaXMP(data []byte) (map[string]string, []ventanaAOI, error) {
+	scan := make(map[string]string)
+	var aois []ventanaAOI
+
+	decoder := xml.NewDecoder(bytes.NewReader(bytes.TrimRight(data, "\x00")))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return scan, aois, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch element.Name.Local {
+		case "iScan":
+			for _, attr := range element.Attr {
+				scan[attr.Name.Local] = attr.Value
+			}
+		case "ImageInfo":
+			aois = append(aois, ventanaAOI{
+				NumRows: xmlIntAttr(element, "NumRows"),
+				NumCols: xmlIntAttr(element, "NumCols"),
+			})
+		case "TileJointInfo":
+			if len(aois) == 0 {
+				continue
+			}
+			aoi := &aois[len(aois)-1]
+			aoi.Joints = append(aoi.Joints, ventanaJoint{
+				Joined:   xmlIntAttr(element, "FlagJoined") == 1,
+				Tile1:    xmlIntAttr(element, "Tile1"),
+				Tile2:    xmlIntAttr(element, "Tile2"),
+				OverlapX: xmlIntAttr(element, "OverlapX"),
+				OverlapY: xmlIntAttr(element, "OverlapY"),
+			})
+		}
+	}
+}
+
+func xmlIntAttr(element xml.StartElement, name string) int {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			v, _ := strconv.Atoi(strings.TrimSpace(attr.Value))
+			return v
+		}
+	}
+	return 0
+}
+
+// newStitchGrid positions the scan tiles of the level 0 from the overlaps of their joints.
+// The overlaps are averaged per column and per row, joints missing from the XMP get the average overlap.
+func newStitchGrid(level model.TIFFDirectory, aoi ventanaAOI) (*StitchGrid, error) {
+	imageTags, err := level.Tags(tags.ImageWidth, tags.ImageLength, tags.TileWidth, tags.TileLength)
+	if err != nil {
+		return nil, fmt.Errorf("missing required tags: %w", err)
+	}
+	imageWidth := int(imageTags[0].GetUintVal(0))
+	imageHeight := int(imageTags[1].GetUintVal(0))
+	tileWidth := int(imageTags[2].GetUintVal(0))
+	tileHeight := int(imageTags[3].GetUintVal(0))
+	if tileWidth == 0 || tileHeight == 0 {
+		return nil, fmt.Errorf("invalid tile size %dx%d", tileWidth, tileHeight)
+	}
+	columns, rows := ceilDiv(imageWidth, tileWidth), ceilDiv(imageHeight, tileHeight)
+	if aoi.NumCols != columns || aoi.NumRows != rows {
+		return nil, fmt.Errorf("area of interest of %dx%d tiles, level 0 of %dx%d tiles", aoi.NumCols, aoi.NumRows, columns, rows)
+	}
+
+	columnOverlaps := newOverlaps(columns - 1)
+	rowOverlaps := newOverlaps(rows - 1)
+	for _, joint := range aoi.Joints {
+		if !joint.Joined {
+			continue
+		}
+		col1, row1, ok1 := serpentinePosition(joint.Tile1, columns, rows)
+		col2, row2, ok2 := serpentinePosition(joint.Tile2, columns, rows)
+		switch {
+		case !ok1 || !ok2:
+		case row1 == row2 && (col2-col1 == 1 || col1-col2 == 1):
+			columnOverlaps.add(min(col1, col2), joint.OverlapX)
+		case col1 == col2 && (row2-row1 == 1 || row1-row2 == 1):
+			rowOverlaps.add(min(row1, row2), joint.OverlapY)
+		}
+	}
+
+	grid := &StitchGrid{
+		Columns:         columns,
+		Rows:            rows,
+		TileWidth:       tileWidth,
+		TileHeight:      tileHeight,
+		ColumnPositions: columnOverlaps.positions(tileWidth),
+		RowPositions:    rowOverlaps.positions(tileHeight),
+	}
+	grid.Width = grid.ColumnPositions[columns-1] + imageWidth - (columns-1)*tileWidth
+	grid.Height = grid.RowPositions[rows-1] + imageHeight - (rows-1)*tileHeight
+	return grid, nil
+}
+
+// serpentinePosition converts the 1-based number of a scan tile into its column and row in the TIFF grid.
+// The scan starts in the lower left corner and proceeds in a serpentine pattern, right along the bottom row, then up and left.
+func serpentinePosition(tile, columns, rows int) (int, int, bool) {
+	i := tile - 1
+	if i < 0 || i >= columns*rows {
+		return 0, 0, false
+	}
+	rowFromBottom, col := i/columns, i%columns
+	if rowFromBottom%2 == 1 {
+		col = columns - 1 - col
+	}
+	return col, rows - 1 - rowFromBottom, true
+}
+
+// overlaps accumulates the overlaps measured between consecutive columns (or rows) of scan tiles.
+type overlaps struct {
+	sums   []int
+	counts []int
+}
+
+func newOverlaps(n int) *overlaps {
+	return &overlaps{sums: make([]int, max(n, 0)), counts: make([]int, max(n, 0))}
+}
+
+func (o *overlaps) add(idx, overlap int) {
+	o.sums[idx] += overlap
+	o.counts[idx]++
+}
+
+// positions returns the edge of each column (or row) of scan tiles in the stitched image.
+func (o *overlaps) positions(tileSize int) []int {
+	var sum, count int
+	for i := range o.sums {
+		sum += o.sums[i]
+		count += o.counts[i]
+	}
+	average := 0
+	if count > 0 {
+		average = sum / count
+	}
+
+	positions := make([]int, len(o.sums)+1)
+	for i := range o.sums {
+		overlap := average
+		if o.counts[i] > 0 {
+			overlap = o.sums[i] / o.counts[i]
+		}
+		positions[i+1] = positions[i] + tileSize - min(max(overlap, 0), tileSize-1)
+	}
+	return positions
+}
+
+func mapsKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,40 @@
+package tiff
+
+import "fmt"
+
+type BinaryReader interface {
+	open(name string) error
+	close() error
+	read(offset uint64, p []byte) (n int, err error)
+}
+
+type CachedBinaryReader interface {
+	BinaryReader
+	openMetadata()
+	closeMetadata()
+	readBlock(offset, size uint64) error
+	Stats() CacheStats
+	MetadataStats() CacheStats
+}
+
+// sliceReader is implemented by the readers able to return bytes without copying them.
+// The bytes returned may be shared with the reader: they must not be modified, and are valid until it is closed.
+type sliceReader interface {
+	slice(offset, size uint64) ([]byte, error)
+}
+
+// sliceFrom returns size bytes at offset, without any copy when the reader supports it.
+func sliceFrom(binary BinaryReader, offset, size uint64) ([]byte, error) {
+	if s, ok := binary.(sliceReader); ok {
+		return s.slice(offset, size)
+	}
+	buffer := make([]byte, size)
+	n, err := binary.read(offset, buffer)
+	if err != nil {
+		return nil, err
+	}
+	if n != int(size) {
+		return nil, fmt.Errorf("unexpected end when reading: expected %d, got %d", size, n)
+	}
+	return buffer, nil
+}
@@ -0,0 +1,228 @@
+package tiff
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const IFDCommonSize = 20 * 20
+
+// metadataBlockSize is the granularity of the read-ahead performed on a cache miss while metadata is being read.
+// Out-of-line tag values are usually written next to their IFD, so a single block often serves several tags.
+const metadataBlockSize = 4096
+
+// CacheStats reports how the reads were served by a CacheBinaryReader.
+type CacheStats struct {
+	Hits         uint64 // reads served from the cached segments
+	Misses       uint64 // reads not covered by the cached segments
+	BackendReads uint64 // reads issued to the underlying BinaryReader
+}
+
+func (s CacheStats) sub(o CacheStats) CacheStats {
+	return CacheStats{Hits: s.Hits - o.Hits, Misses: s.Misses - o.Misses, BackendReads: s.BackendReads - o.BackendReads}
+}
+
+// segment is a contiguous range of the file held in memory.
+type segment struct {
+	start uint64
+	data  []byte
+}
+
+func (s segment) end() uint64 {
+	return s.start + uint64(len(s.data))
+}
+
+// CacheBinaryReader keeps the blocks read while the metadata is decoded, so that the many small reads
+// of IFD entries and tag values are served from memory instead of reaching the underlying reader.
+// Segments are kept sorted and merged, so the lookup is a binary search.
+// The cache is only active between openMetadata and closeMetadata, tile data is never cached.
+type CacheBinaryReader struct {
+	binary       BinaryReader
+	segments     []segment // sorted by start, never overlapping nor adjacent
+	active       bool
+	lock         sync.RWMutex
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	backendReads atomic.Uint64
+	baseline     CacheStats // counters when the metadata phase started
+	metadata     CacheStats // counters of the last metadata phase, once closed
+}
+
+func NewCacheBinaryReader(binary BinaryReader) CachedBinaryReader {
+	return &CacheBinaryReader{
+		binary: binary,
+	}
+}
+
+func (f *CacheBinaryReader) open(name string) error {
+	return f.binary.open(name)
+}
+
+func (f *CacheBinaryReader) close() error {
+	return f.binary.close()
+}
+
+func (f *CacheBinaryReader) openMetadata() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.active = true
+	f.baseline = f.Stats()
+	f.metadata = CacheStats{}
+}
+
+func (f *CacheBinaryReader) closeMetadata() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.active = false
+	f.segments = nil
+	f.metadata = f.Stats().sub(f.baseline)
+}
+
+// MetadataStats returns the cache counters of the metadata phase, the one in progress or else the last one,
+// leaving out the tile reads and the lazy tag loads performed once the metadata is read.
+func (f *CacheBinaryReader) MetadataStats() CacheStats {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.active {
+		return f.Stats().sub(f.baseline)
+	}
+	return f.metadata
+}
+
+// Stats returns the cache counters accumulated since the reader was created.
+func (f *CacheBinaryReader) Stats() CacheStats {
+	return CacheStats{
+		Hits:         f.hits.Load(),
+		Misses:       f.misses.Load(),
+		BackendReads: f.backendReads.Load(),
+	}
+}
+
+func (f *CacheBinaryReader) readBlock(offset, size uint64) error {
+	if f.within(offset, size) {
+		return nil
+	}
+	buffer := make([]byte, size)
+	n, err := f.readBackend(offset, buffer)
+	f.insert(offset, buffer[:n])
+	if errors.Is(err, io.EOF) && n > 0 {
+		// a block is a speculative read, it may go past the end of the file
+		return nil
+	}
+	return err
+}
+
+func (f *CacheBinaryReader) read(offset uint64, p []byte) (int, error) {
+	if f.lookup(offset, p) {
+		f.hits.Add(1)
+		return len(p), nil
+	}
+	f.misses.Add(1)
+
+	if !f.isActive() || len(p) >= metadataBlockSize {
+		return f.readBackend(offset, p)
+	}
+
+	// read ahead an aligned block around the requested range
+	start := offset - offset%metadataBlockSize
+	end := offset + uint64(len(p))
+	end += (metadataBlockSize - end%metadataBlockSize) % metadataBlockSize
+	buffer := make([]byte, end-start)
+	n, err := f.readBackend(start, buffer)
+	f.insert(start, buffer[:n])
+
+	skip := int(offset - start)
+	if n >= skip+len(p) {
+		return copy(p, buffer[skip:n]), nil
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	return copy(p, buffer[min(skip, n):n]), err
+}
+
+// slice is never served from the cache, it is only used to read tile and strip data.
+func (f *CacheBinaryReader) slice(offset, size uint64) ([]byte, error) {
+	f.backendReads.Add(1)
+	return sliceFrom(f.binary, offset, size)
+}
+
+func (f *CacheBinaryReader) readBackend(offset uint64, p []byte) (int, error) {
+	f.backendReads.Add(1)
+	return f.binary.read(offset, p)
+}
+
+func (f *CacheBinaryReader) isActive() bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.active
+}
+
+// within tells if the range [offset, offset+size) is entirely held by a single segment.
+func (f *CacheBinaryReader) within(offset, size uint64) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	_, ok := f.find(offset, size)
+	return ok
+}
+
+// lookup copies the range starting at offset into p when it is entirely cached.
+func (f *CacheBinaryReader) lookup(offset uint64, p []byte) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	s, ok := f.find(offset, uint64(len(p)))
+	if !ok {
+		return false
+	}
+	b := offset - s.start
+	copy(p, s.data[b:b+uint64(len(p))])
+	return true
+}
+
+// find returns the segment covering [offset, offset+size), the caller must hold the lock.
+func (f *CacheBinaryReader) find(offset, size uint64) (segment, bool) {
+	// first segment starting after offset, the candidate is the one before
+	i := sort.Search(len(f.segments), func(i int) bool { return f.segments[i].start > offset })
+	if i == 0 {
+		return segment{}, false
+	}
+	s := f.segments[i-1]
+	if offset+size > s.end() {
+		return segment{}, false
+	}
+	return s, true
+}
+
+// insert adds the block to the cache, merging it with every segment it overlaps or touches.
+func (f *CacheBinaryReader) insert(offset uint64, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if !f.active {
+		return
+	}
+
+	end := offset + uint64(len(data))
+	lo := sort.Search(len(f.segments), func(i int) bool { return f.segments[i].end() >= offset })
+	hi := sort.Search(len(f.segments), func(i int) bool { return f.segments[i].start > end })
+
+	merged := segment{start: offset, data: data}
+	if lo < hi {
+		start := min(offset, f.segments[lo].start)
+		stop := max(end, f.segments[hi-1].end())
+		buffer := make([]byte, stop-start)
+		for _, s := range f.segments[lo:hi] {
+			copy(buffer[s.start-start:], s.data)
+		}
+		// the freshest bytes win
+		copy(buffer[offset-start:], data)
+		merged = segment{start: start, data: buffer}
+	}
+
+	f.segments = append(f.segments[:lo], append([]segment{merged}, f.segments[hi:]...)...)
+}
@@ -0,0 +1,116 @@
+package tiff
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// memoryBinaryReader serves a byte slice, counting nothing: the counters under test are the cache ones.
+type memoryBinaryReader struct {
+	data []byte
+}
+
+func (m *memoryBinaryReader) open(string) error { return nil }
+
+func (m *memoryBinaryReader) close() error { return nil }
+
+func (m *memoryBinaryReader) read(offset uint64, p []byte) (int, error) {
+	if offset >= uint64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestCacheBinaryReaderStats(t *testing.T) {
+	data := make([]byte, 3*metadataBlockSize)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	type access struct {
+		offset uint64
+		size   int
+		block  bool // readBlock instead of read
+	}
+	tests := []struct {
+		name         string
+		metadata     []access // performed between openMetadata and closeMetadata
+		after        []access // performed once the metadata is read
+		wantMetadata CacheStats
+		wantTotal    CacheStats
+	}{
+		{
+			name:         "miss then hit in the same block",
+			metadata:     []access{{offset: 0, size: 8}, {offset: 16, size: 8}, {offset: 4000, size: 12}},
+			wantMetadata: CacheStats{Hits: 2, Misses: 1, BackendReads: 1},
+			wantTotal:    CacheStats{Hits: 2, Misses: 1, BackendReads: 1},
+		},
+		{
+			name:         "read across two blocks",
+			metadata:     []access{{offset: metadataBlockSize - 4, size: 12}, {offset: metadataBlockSize + 100, size: 4}},
+			wantMetadata: CacheStats{Hits: 1, Misses: 1, BackendReads: 1},
+			wantTotal:    CacheStats{Hits: 1, Misses: 1, BackendReads: 1},
+		},
+		{
+			name:         "large read not cached",
+			metadata:     []access{{offset: 0, size: metadataBlockSize}, {offset: 0, size: 8}},
+			wantMetadata: CacheStats{Hits: 0, Misses: 2, BackendReads: 2},
+			wantTotal:    CacheStats{Hits: 0, Misses: 2, BackendReads: 2},
+		},
+		{
+			name:         "readBlock prefetch",
+			metadata:     []access{{offset: 100, size: 200, block: true}, {offset: 150, size: 8}, {offset: 120, size: 4, block: true}},
+			wantMetadata: CacheStats{Hits: 1, Misses: 0, BackendReads: 1},
+			wantTotal:    CacheStats{Hits: 1, Misses: 0, BackendReads: 1},
+		},
+		{
+			name:         "reads after the metadata left out",
+			metadata:     []access{{offset: 0, size: 8}, {offset: 8, size: 8}},
+			after:        []access{{offset: 0, size: 8}, {offset: 8, size: 8}, {offset: 16, size: 8}},
+			wantMetadata: CacheStats{Hits: 1, Misses: 1, BackendReads: 1},
+			wantTotal:    CacheStats{Hits: 1, Misses: 4, BackendReads: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewCacheBinaryReader(&memoryBinaryReader{data: data})
+			perform := func(accesses []access) {
+				for _, a := range accesses {
+					if a.block {
+						if err := reader.readBlock(a.offset, uint64(a.size)); err != nil {
+							t.Fatalf("readBlock(%d, %d): %v", a.offset, a.size, err)
+						}
+						continue
+					}
+					p := make([]byte, a.size)
+					if _, err := reader.read(a.offset, p); err != nil {
+						t.Fatalf("read(%d, %d): %v", a.offset, a.size, err)
+					}
+					if want := data[a.offset : a.offset+uint64(a.size)]; !bytes.Equal(p, want) {
+						t.Fatalf("read(%d, %d) returned wrong bytes", a.offset, a.size)
+					}
+				}
+			}
+
+			reader.openMetadata()
+			perform(tt.metadata)
+			if got := reader.MetadataStats(); got != tt.wantMetadata {
+				t.Errorf("MetadataStats() during the metadata phase = %+v, want %+v", got, tt.wantMetadata)
+			}
+			reader.closeMetadata()
+			perform(tt.after)
+
+			if got := reader.MetadataStats(); got != tt.wantMetadata {
+				t.Errorf("MetadataStats() = %+v, want %+v", got, tt.wantMetadata)
+			}
+			if got := reader.Stats(); got != tt.wantTotal {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+// Package tiff reads TIFF and BigTIFF files from any storage: local files (pread or mmap),
+// S3-compatible buckets, web servers supporting HTTP Range requests or any io.ReaderAt.
+//
+// The metadata is read with few and coalesced requests (see FastTiffReader),
+// the large tag arrays are loaded lazily.
+//
+// The exported API of this package follows semantic versioning.
+package tiff
+
+import "github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+
+// TIFFDirectory is an Image File Directory (IFD): the tags describing a single image of the file.
+type TIFFDirectory = model.TIFFDirectory
+
+// TIFFMetadata is the list of the directories of the file, in the order of the IFD chain.
+type TIFFMetadata = model.TIFFMetadata
+
+// TIFFTag is a tag of a directory with its values.
+type TIFFTag = model.TIFFTag
@@ -0,0 +1,279 @@
+package tiff
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"log/slog"
+	"slices"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultCoalesceGap  = 64 * 1024        // largest hole between two values still read in a single request
+	defaultMaxRangeSize = 16 * 1024 * 1024 // largest coalesced request
+	defaultParallelism  = 8                // concurrent requests issued to the storage
+)
+
+// FastTiffReader is a TiffReader reading the metadata in two passes, to be efficient over high-latency storage.
+// The IFD chain is walked first, collecting the values stored outside the entries
+// (TileOffsets, TileByteCounts, JPEGTables, ICC profile...), except the large ones which are loaded lazily.
+// Those values are then fetched concurrently, neighbouring ranges being coalesced into single reads.
+type FastTiffReader struct {
+	*TiffReader
+	coalesceGap  uint64
+	maxRangeSize uint64
+	parallelism  int
+}
+
+// valueRange locates the out-of-line values of an entry in the file.
+type valueRange struct {
+	offset uint64
+	size   uint64
+	ifd    int
+	entry  int
+}
+
+// coalescedRange is a single read covering the values of one or more entries.
+type coalescedRange struct {
+	offset uint64
+	size   uint64
+	values []valueRange
+	data   []byte
+}
+
+func NewFastTiffReader(binary CachedBinaryReader) *FastTiffReader {
+	return &FastTiffReader{
+		TiffReader:   NewTiffReader(binary),
+		coalesceGap:  defaultCoalesceGap,
+		maxRangeSize: defaultMaxRangeSize,
+		parallelism:  defaultParallelism,
+	}
+}
+
+// rawDirectory is a directory whose out-of-line values are not read yet.
+type rawDirectory struct {
+	entries []rawTag
+	subIFDs []int              // indexes of the child directories in the walk
+	private map[tags.TagID]int // indexes of the EXIF, GPS and Interoperability directories in the walk
+}
+
+// ReadMetadata reads the TIFF metadata from the image file.
+// The result is the same as TiffReader.ReadMetadata.
+func (r *FastTiffReader) ReadMetadata() (model.TIFFMetadata, error) {
+	r.binary.openMetadata()
+	defer r.binary.closeMetadata()
+
+	nextOffset, err := r.readHeader()
+	if err != nil {
+		return model.TIFFMetadata{}, fmt.Errorf("unable to read header: %s", err)
+	}
+
+	// first pass: walk the IFD chain and the SubIFDs, only the entries are read
+	var directories []*rawDirectory
+	roots, err := r.walkIFDChain(nextOffset, make(map[uint64]bool), 0, &directories)
+	if err != nil {
+		return model.TIFFMetadata{}, err
+	}
+
+	// second pass: fetch the out-of-line values
+	var values []valueRange
+	for i, directory := range directories {
+		for j, entry := range directory.entries {
+			if !r.isInline(entry) && !r.isLazy(entry) {
+				values = append(values, valueRange{offset: r.valueOffset(entry), size: entry.size(), ifd: i, entry: j})
+			}
+		}
+	}
+	ranges := coalesceRanges(values, r.coalesceGap, r.maxRangeSize)
+	if err = r.readRanges(ranges); err != nil {
+		return model.TIFFMetadata{}, err
+	}
+
+	// index the data of every out-of-line entry
+	outOfLine := make([]map[int][]byte, len(directories))
+	for i := range outOfLine {
+		outOfLine[i] = make(map[int][]byte)
+	}
+	for _, rg := range ranges {
+		for _, v := range rg.values {
+			start := v.offset - rg.offset
+			outOfLine[v.ifd][v.entry] = rg.data[start : start+v.size]
+		}
+	}
+
+	// decode the values, children are always walked after their parent
+	decoded := make([]model.TIFFDirectory, len(directories))
+	for i := len(directories) - 1; i >= 0; i-- {
+		tagMap := make(map[tags.TagID]model.TIFFTag, len(directories[i].entries))
+		for j, entry := range directories[i].entries {
+			if r.isLazy(entry) {
+				tagMap[tags.TagID(entry.tagID)] = r.lazyTag(entry)
+				continue
+			}
+			data, ok := outOfLine[i][j]
+			if !ok {
+				data = entry.field[:entry.size()]
+			}
+			tag, err := r.decodeTagValues(entry, data)
+			if err != nil {
+				return model.TIFFMetadata{}, fmt.Errorf("unable to read IDF: readIFD: cannot read Tag: %w", err)
+			}
+			tagMap[tag.GetTagID()] = tag
+		}
+		ifd := model.NewTIFFDirectory(tagMap)
+		if len(directories[i].subIFDs) > 0 {
+			subIFDs := make(model.TIFFMetadata, 0, len(directories[i].subIFDs))
+			for _, child := range directories[i].subIFDs {
+				subIFDs = append(subIFDs, decoded[child])
+			}
+			ifd = ifd.WithSubIFDs(subIFDs)
+		}
+		for tagID, child := range directories[i].private {
+			ifd = ifd.WithPrivateIFD(tagID, decoded[child])
+		}
+		decoded[i] = ifd
+	}
+
+	metadata := make(model.TIFFMetadata, 0, len(roots))
+	for _, root := range roots {
+		metadata = append(metadata, decoded[root])
+		slog.Debug("Metadata", "IFD", decoded[root])
+	}
+
+	slog.Debug("Metadata read", "directories", len(directories), "ranges", len(ranges), "values", len(values), "cache", r.binary.MetadataStats())
+	return metadata, nil
+}
+
+// walkIFDChain reads the entries of the directories linked from offset and of their SubIFDs.
+// The directories are appended to all, the indexes of the chain are returned.
+func (r *FastTiffReader) walkIFDChain(nextOffset uint64, visited map[uint64]bool, depth int, all *[]*rawDirectory) ([]int, error) {
+	var chain []int
+	for nextOffset != 0 {
+		if visited[nextOffset] {
+			return nil, fmt.Errorf("IFD chain loops at offset %d", nextOffset)
+		}
+		visited[nextOffset] = true
+
+		entries, next, err := r.readDirectoryEntries(nextOffset)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read IDF: %s", err)
+		}
+		directory := &rawDirectory{entries: entries}
+		chain = append(chain, len(*all))
+		*all = append(*all, directory)
+
+		offsets, err := r.rawSubIFDOffsets(entries, depth)
+		if err != nil {
+			return nil, err
+		}
+		for _, offset := range offsets {
+			children, err := r.walkIFDChain(offset, visited, depth+1, all)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read SubIFD: %w", err)
+			}
+			directory.subIFDs = append(directory.subIFDs, children...)
+		}
+		r.walkPrivateIFDs(directory, visited, depth, all)
+		nextOffset = next
+	}
+	return chain, nil
+}
+
+// walkPrivateIFDs reads the entries of the EXIF, GPS and Interoperability directories referenced by directory.
+// As for TiffReader, a malformed one is logged and skipped.
+func (r *FastTiffReader) walkPrivateIFDs(directory *rawDirectory, visited map[uint64]bool, depth int, all *[]*rawDirectory) {
+	pointers := r.rawDirectoryOf(directory.entries, privateIFDTags...)
+	for _, tagID := range privateIFDTags {
+		offset, ok := r.privateIFDOffset(pointers, tagID, visited, depth)
+		if !ok {
+			continue
+		}
+		visited[offset] = true
+
+		entries, _, err := r.readDirectoryEntries(offset)
+		if err != nil {
+			slog.Warn("Unable to read private IFD, ignored", "tag", tags.IDsLabels[tagID], "offset", offset, "error", err)
+			continue
+		}
+		private := &rawDirectory{entries: entries}
+		if directory.private == nil {
+			directory.private = make(map[tags.TagID]int)
+		}
+		directory.private[tagID] = len(*all)
+		*all = append(*all, private)
+		r.walkPrivateIFDs(private, visited, depth+1, all)
+	}
+}
+
+// rawDirectoryOf decodes the given entries into a directory, pointer tags being inline no extra read is needed.
+func (r *FastTiffReader) rawDirectoryOf(entries []rawTag, tagIDs ...tags.TagID) model.TIFFDirectory {
+	tagMap := make(map[tags.TagID]model.TIFFTag)
+	for _, entry := range entries {
+		if !slices.Contains(tagIDs, tags.TagID(entry.tagID)) || !r.isInline(entry) {
+			continue
+		}
+		if tag, err := r.decodeTagValues(entry, entry.field[:entry.size()]); err == nil {
+			tagMap[tag.GetTagID()] = tag
+		}
+	}
+	return model.NewTIFFDirectory(tagMap)
+}
+
+// rawSubIFDOffsets reads the offsets of the SubIFDs tag among the entries, if any.
+func (r *FastTiffReader) rawSubIFDOffsets(entries []rawTag, depth int) ([]uint64, error) {
+	for _, entry := range entries {
+		if tags.TagID(entry.tagID) != tags.SubIFDs {
+			continue
+		}
+		tag, err := r.readTagValues(entry)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read SubIFDs: %w", err)
+		}
+		return r.subIFDOffsets(model.NewTIFFDirectory(map[tags.TagID]model.TIFFTag{tags.SubIFDs: tag}), depth)
+	}
+	return nil, nil
+}
+
+// readRanges reads the coalesced ranges concurrently.
+func (r *FastTiffReader) readRanges(ranges []*coalescedRange) error {
+	var g errgroup.Group
+	g.SetLimit(r.parallelism)
+	for _, rg := range ranges {
+		g.Go(func() error {
+			data, err := r.readBytesAt(rg.offset, rg.size)
+			if err != nil {
+				return fmt.Errorf("unable to read tag values: %w", err)
+			}
+			rg.data = data
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// coalesceRanges groups the values whose ranges are separated by at most gap bytes,
+// as long as the resulting read does not exceed maxSize.
+func coalesceRanges(values []valueRange, gap, maxSize uint64) []*coalescedRange {
+	values = slices.Clone(values)
+	slices.SortFunc(values, func(a, b valueRange) int { return cmp.Compare(a.offset, b.offset) })
+
+	var ranges []*coalescedRange
+	var current *coalescedRange
+	for _, v := range values {
+		if current != nil {
+			end := current.offset + current.size
+			newEnd := max(end, v.offset+v.size)
+			if v.offset <= end+gap && newEnd-current.offset <= maxSize {
+				current.size = newEnd - current.offset
+				current.values = append(current.values, v)
+				continue
+			}
+		}
+		current = &coalescedRange{offset: v.offset, size: v.size, values: []valueRange{v}}
+		ranges = append(ranges, current)
+	}
+	return ranges
+}
@@ -0,0 +1,308 @@
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+)
+
+// testEntry is an entry of a directory written by testTIFFWriter, values being a string or a slice of fixed-size values.
+type testEntry struct {
+	tagID   tags.TagID
+	tagType uint16
+	values  any
+}
+
+// testIFD is a directory written by testTIFFWriter, with its SubIFDs and its EXIF directory.
+type testIFD struct {
+	entries []testEntry
+	subIFDs []testIFD
+	exif    *testIFD
+}
+
+// testTIFFWriter writes little-endian classic TIFF or BigTIFF files, the values being written before their directory.
+type testTIFFWriter struct {
+	big bool
+	buf []byte
+}
+
+func (w *testTIFFWriter) write(chain []testIFD) []byte {
+	if w.big {
+		w.buf = []byte{'I', 'I', 0x2b, 0x00, 8, 0, 0, 0}
+	} else {
+		w.buf = []byte{'I', 'I', 0x2a, 0x00}
+	}
+	next := len(w.buf)
+	w.buf = append(w.buf, w.offset(0)...)
+	for _, ifd := range chain {
+		start, nextField := w.writeIFD(ifd)
+		copy(w.buf[next:], w.offset(start))
+		next = nextField
+	}
+	return w.buf
+}
+
+// writeIFD writes the directory and returns its offset and the position of its next IFD offset.
+func (w *testTIFFWriter) writeIFD(ifd testIFD) (uint64, int) {
+	entries := slices.Clone(ifd.entries)
+	if len(ifd.subIFDs) > 0 {
+		var offsets []uint64
+		for _, sub := range ifd.subIFDs {
+			offset, _ := w.writeIFD(sub)
+			offsets = append(offsets, offset)
+		}
+		entries = append(entries, w.pointerEntry(tags.SubIFDs, offsets))
+	}
+	if ifd.exif != nil {
+		offset, _ := w.writeIFD(*ifd.exif)
+		entries = append(entries, w.pointerEntry(tags.ExifIFD, []uint64{offset}))
+	}
+	slices.SortFunc(entries, func(a, b testEntry) int { return int(a.tagID) - int(b.tagID) })
+
+	fields := make([][]byte, len(entries))
+	counts := make([]uint64, len(entries))
+	for i, entry := range entries {
+		data := entryBytes(entry)
+		counts[i] = uint64(len(data)) / tagTypeSize(entry.tagType)
+		if len(data) <= len(w.offset(0)) {
+			fields[i] = append(data, make([]byte, len(w.offset(0))-len(data))...)
+			continue
+		}
+		w.align()
+		fields[i] = w.offset(uint64(len(w.buf)))
+		w.buf = append(w.buf, data...)
+	}
+
+	w.align()
+	start := uint64(len(w.buf))
+	w.buf = append(w.buf, w.count(uint64(len(entries)), 2)...)
+	for i, entry := range entries {
+		w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(entry.tagID))
+		w.buf = binary.LittleEndian.AppendUint16(w.buf, entry.tagType)
+		w.buf = append(w.buf, w.count(counts[i], 4)...)
+		w.buf = append(w.buf, fields[i]...)
+	}
+	nextField := len(w.buf)
+	w.buf = append(w.buf, w.offset(0)...)
+	return start, nextField
+}
+
+func (w *testTIFFWriter) pointerEntry(tagID tags.TagID, offsets []uint64) testEntry {
+	if w.big {
+		return testEntry{tagID, 0x12, offsets}
+	}
+	values := make([]uint32, len(offsets))
+	for i, offset := range offsets {
+		values[i] = uint32(offset)
+	}
+	return testEntry{tagID, 0xd, values}
+}
+
+func (w *testTIFFWriter) offset(v uint64) []byte {
+	if w.big {
+		return binary.LittleEndian.AppendUint64(nil, v)
+	}
+	return binary.LittleEndian.AppendUint32(nil, uint32(v))
+}
+
+// count encodes a number of entries or values, on 8 bytes in BigTIFF files.
+func (w *testTIFFWriter) count(v uint64, classicSize int) []byte {
+	if w.big {
+		return binary.LittleEndian.AppendUint64(nil, v)
+	}
+	if classicSize == 2 {
+		return binary.LittleEndian.AppendUint16(nil, uint16(v))
+	}
+	return binary.LittleEndian.AppendUint32(nil, uint32(v))
+}
+
+func (w *testTIFFWriter) align() {
+	if len(w.buf)%2 == 1 {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func entryBytes(entry testEntry) []byte {
+	if s, ok := entry.values.(string); ok {
+		return append([]byte(s), 0)
+	}
+	var buffer bytes.Buffer
+	if err := binary.Write(&buffer, binary.LittleEndian, entry.values); err != nil {
+		panic(fmt.Sprintf("unsupported test values %T", entry.values))
+	}
+	return buffer.Bytes()
+}
+
+func sequence[T uint16 | uint32 | uint64](n int, first, step T) []T {
+	values := make([]T, n)
+	for i := range values {
+		values[i] = first + T(i)*step
+	}
+	return values
+}
+
+func TestFastTiffReaderReadMetadataEquivalence(t *testing.T) {
+	exif := testIFD{entries: []testEntry{
+		{tags.ExposureTime, 0x5, []uint32{1, 250}},
+		{tags.DateTimeOriginal, 0x2, "2025:02:24 10:00:00"},
+	}}
+	baseline := func(width uint32, tiles int, offsets any, offsetsType uint16) []testEntry {
+		return []testEntry{
+			{tags.NewSubfileType, 0x4, []uint32{0}},
+			{tags.ImageWidth, 0x4, []uint32{width}},
+			{tags.ImageLength, 0x4, []uint32{width / 2}},
+			{tags.BitsPerSample, 0x3, []uint16{8, 8, 8}},
+			{tags.Compression, 0x3, []uint16{7}},
+			{tags.ImageDescription, 0x2, fmt.Sprintf("Aperio Image Library|%d x %d|MPP = 0.25", width, width/2)},
+			{tags.XResolution, 0x5, []uint32{40000, 1}},
+			{tags.TileWidth, 0x3, []uint16{256}},
+			{tags.TileLength, 0x3, []uint16{256}},
+			{tags.TileOffsets, offsetsType, offsets},
+			{tags.TileByteCounts, 0x3, sequence[uint16](tiles, 1000, 3)},
+			{tags.JPEGTables, 0x7, bytes.Repeat([]byte{0xff, 0xd8, 0xdb}, 100)},
+			{tags.SMinSampleValue, 0x8, []int16{-3, -2, -1}},
+			{tags.TagID(65000), 0xc, []float64{0.25, 0.5}}, // private tag
+		}
+	}
+
+	tests := []struct {
+		name  string
+		big   bool
+		chain []testIFD
+	}{
+		{
+			name: "classic TIFF",
+			chain: []testIFD{
+				{entries: baseline(4096, 6000, sequence[uint32](6000, 1<<20, 4096), 0x4), exif: &exif},
+				{entries: baseline(1024, 16, sequence[uint32](16, 1<<24, 512), 0x4)},
+				{entries: []testEntry{{tags.ImageWidth, 0x3, []uint16{64}}, {tags.Software, 0x2, "thumbnail"}}},
+			},
+		},
+		{
+			name: "BigTIFF",
+			big:  true,
+			chain: []testIFD{
+				{entries: baseline(4096, 3000, sequence[uint64](3000, 1<<33, 4096), 0x10), exif: &exif},
+				{entries: baseline(1024, 16, sequence[uint64](16, 1<<34, 512), 0x10)},
+			},
+		},
+		{
+			name: "SubIFDs",
+			chain: []testIFD{
+				{
+					entries: baseline(4096, 64, sequence[uint32](64, 1<<20, 4096), 0x4),
+					subIFDs: []testIFD{
+						{entries: baseline(2048, 16, sequence[uint32](16, 1<<21, 4096), 0x4)},
+						{
+							entries: baseline(1024, 4, sequence[uint32](4, 1<<22, 4096), 0x4),
+							subIFDs: []testIFD{{entries: baseline(512, 1, []uint32{1 << 23}, 0x4)}},
+						},
+					},
+				},
+				{entries: baseline(256, 1, []uint32{1 << 24}, 0x4), exif: &exif},
+			},
+		},
+		{
+			name: "BigTIFF SubIFDs",
+			big:  true,
+			chain: []testIFD{{
+				entries: baseline(4096, 64, sequence[uint64](64, 1<<33, 4096), 0x10),
+				subIFDs: []testIFD{{entries: baseline(2048, 16, sequence[uint64](16, 1<<34, 4096), 0x10)}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := (&testTIFFWriter{big: tt.big}).write(tt.chain)
+			readerAt := func() CachedBinaryReader {
+				return NewCacheBinaryReader(NewReaderAtBinaryReader(bytes.NewReader(data), int64(len(data))))
+			}
+
+			want, err := NewTiffReader(readerAt()).ReadMetadata()
+			if err != nil {
+				t.Fatalf("TiffReader.ReadMetadata: %v", err)
+			}
+			got, err := NewFastTiffReader(readerAt()).ReadMetadata()
+			if err != nil {
+				t.Fatalf("FastTiffReader.ReadMetadata: %v", err)
+			}
+			compareMetadata(t, "IFD", tt.chain, want, got)
+		})
+	}
+}
+
+// compareMetadata compares the directories read by both readers, the tags compared being the ones written.
+func compareMetadata(t *testing.T, path string, chain []testIFD, want, got model.TIFFMetadata) {
+	t.Helper()
+	if len(want) != len(chain) || len(got) != len(chain) {
+		t.Fatalf("%s: %d directories written, TiffReader read %d, FastTiffReader read %d", path, len(chain), len(want), len(got))
+	}
+	for i, ifd := range chain {
+		compareDirectory(t, fmt.Sprintf("%s[%d]", path, i), ifd, want[i], got[i])
+	}
+}
+
+func compareDirectory(t *testing.T, path string, ifd testIFD, want, got model.TIFFDirectory) {
+	t.Helper()
+	tagIDs := make([]tags.TagID, 0, len(ifd.entries)+2)
+	for _, entry := range ifd.entries {
+		tagIDs = append(tagIDs, entry.tagID)
+	}
+	if len(ifd.subIFDs) > 0 {
+		tagIDs = append(tagIDs, tags.SubIFDs)
+	}
+	if ifd.exif != nil {
+		tagIDs = append(tagIDs, tags.ExifIFD)
+	}
+
+	for _, tagID := range tagIDs {
+		wantTag, err := want.Tag(tagID)
+		if err != nil {
+			t.Errorf("%s: TiffReader: %v", path, err)
+			continue
+		}
+		gotTag, err := got.Tag(tagID)
+		if err != nil {
+			t.Errorf("%s: FastTiffReader: %v", path, err)
+			continue
+		}
+		if wantValues, gotValues := tagValues(wantTag), tagValues(gotTag); !reflect.DeepEqual(wantValues, gotValues) {
+			t.Errorf("%s: tag %d differs\nTiffReader:     %v\nFastTiffReader: %v", path, tagID, wantValues, gotValues)
+		}
+	}
+	// the representation holds every tag, the ones not written included
+	if want.String() != got.String() {
+		t.Errorf("%s: directories differ\nTiffReader:     %s\nFastTiffReader: %s", path, want, got)
+	}
+
+	compareMetadata(t, path+".SubIFDs", ifd.subIFDs, want.SubIFDs(), got.SubIFDs())
+	wantExif, wantErr := want.ExifIFD()
+	gotExif, gotErr := got.ExifIFD()
+	switch {
+	case (wantErr == nil) != (gotErr == nil):
+		t.Errorf("%s: EXIF directory read by TiffReader: %v, by FastTiffReader: %v", path, wantErr == nil, gotErr == nil)
+	case ifd.exif != nil && wantErr == nil:
+		compareDirectory(t, path+".Exif", *ifd.exif, wantExif, gotExif)
+	}
+}
+
+// tagValues returns the values of the tag through every accessor, loading the lazy tags.
+func tagValues(tag model.TIFFTag) []any {
+	values := []any{
+		tag.GetTagID(), tag.ValuesCount(),
+		tag.AsBytes(), tag.AsStrings(), tag.AsUint16s(), tag.AsUint32s(), tag.AsUint64s(),
+		tag.AsRationals(), tag.AsSignedRationals(), tag.AsInt8s(), tag.AsInt16s(), tag.AsInt32s(),
+		tag.AsFloat32s(), tag.AsFloat64s(),
+	}
+	for i := range tag.ValuesCount() {
+		v, err := tag.UintVal(i)
+		values = append(values, v, err == nil)
+	}
+	return values
+}
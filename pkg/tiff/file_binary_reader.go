@@ -1,16 +1,18 @@
-package tiffio
+package tiff
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math"
 	"os"
-	"sync"
 )
 
+// FileBinaryReader reads a local file with positional reads (pread),
+// so that concurrent reads of the same file never wait for each other.
 type FileBinaryReader struct {
 	file *os.File
-	lock sync.Mutex
 }
 
 func NewFileBinaryReader() BinaryReader {
@@ -18,11 +20,9 @@ func NewFileBinaryReader() BinaryReader {
 }
 
 func (f *FileBinaryReader) open(name string) error {
-	f.lock.Lock()
-	defer f.lock.Unlock()
 	if f.file != nil {
 		if err := f.close(); err != nil {
-			slog.Warn("error closing file", err)
+			slog.Warn("error closing file", "error", err)
 		}
 	}
 	file, err := os.Open(name)
@@ -34,17 +34,23 @@ func (f *FileBinaryReader) open(name string) error {
 }
 
 func (f *FileBinaryReader) close() error {
+	if f.file == nil {
+		return nil
+	}
 	slog.Info("closing file", "file", f.file.Name())
 	return f.file.Close()
 }
 
+// read fills p with the bytes starting at offset.
+// As for io.ReaderAt, a short read is reported with an error, io.EOF when the end of the file is reached.
 func (f *FileBinaryReader) read(offset uint64, p []byte) (int, error) {
-	f.lock.Lock()
-	defer f.lock.Unlock()
 	if offset > math.MaxInt64 {
 		return 0, fmt.Errorf("value %d exceeds int64 maximum limit", offset)
 	}
-	_, err := f.file.Seek(int64(offset), 0)
-	n, err := f.file.Read(p)
+	n, err := f.file.ReadAt(p, int64(offset))
+	if errors.Is(err, io.EOF) && n == len(p) {
+		// the requested range ends exactly at the end of the file
+		return n, nil
+	}
 	return n, err
 }
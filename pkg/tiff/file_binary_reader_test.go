@@ -0,0 +1,102 @@
+package tiff
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+)
+
+// lockedFileReader reads with Seek and Read under a mutex, as local files were read before positional reads.
+type lockedFileReader struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+func (f *lockedFileReader) open(name string) error {
+	file, err := os.Open(name)
+	f.file = file
+	return err
+}
+
+func (f *lockedFileReader) close() error {
+	return f.file.Close()
+}
+
+func (f *lockedFileReader) read(offset uint64, p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, err := f.file.Seek(int64(offset), io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(f.file, p)
+}
+
+// BenchmarkTiffReaderGetTileData reads the tiles of a single file from concurrent goroutines,
+// the positional reads being expected to scale with GOMAXPROCS where the locked reads do not.
+//
+//	go test ./pkg/tiff -run '^$' -bench GetTileData -cpu 1,2,4,8
+func BenchmarkTiffReaderGetTileData(b *testing.B) {
+	const (
+		tileCount = 256
+		tileSize  = 64 * 1024
+		dataStart = 1 << 20 // past the metadata
+	)
+	offsets := sequence[uint32](tileCount, dataStart, tileSize)
+	metadata := (&testTIFFWriter{}).write([]testIFD{{entries: []testEntry{
+		{tags.ImageWidth, 0x4, []uint32{16 * 256}},
+		{tags.ImageLength, 0x4, []uint32{16 * 256}},
+		{tags.TileWidth, 0x3, []uint16{256}},
+		{tags.TileLength, 0x3, []uint16{256}},
+		{tags.TileOffsets, 0x4, offsets},
+		{tags.TileByteCounts, 0x4, sequence[uint32](tileCount, tileSize, 0)},
+	}}})
+	data := make([]byte, dataStart+tileCount*tileSize)
+	copy(data, metadata)
+	rand.New(rand.NewSource(1)).Read(data[dataStart:])
+	name := filepath.Join(b.TempDir(), "slide.tiff")
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	benchmarks := []struct {
+		name   string
+		binary BinaryReader
+	}{
+		{"pread", NewFileBinaryReader()},
+		{"seek under lock", &lockedFileReader{}},
+		{"mmap", NewMmapBinaryReader()},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			reader := NewTiffReader(NewCacheBinaryReader(bm.binary))
+			if err := reader.Open(name); err != nil {
+				b.Fatal(err)
+			}
+			defer reader.Close()
+			metadata, err := reader.ReadMetadata()
+			if err != nil {
+				b.Fatal(err)
+			}
+			level := metadata[0]
+
+			var next atomic.Int64
+			b.SetBytes(tileSize)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					tileIdx := int(next.Add(1) % tileCount)
+					if _, err := reader.GetTileData(level, tileIdx); err != nil {
+						b.Errorf("tile %d: %v", tileIdx, err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package tiff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	httpMaxRetries   = 3
+	httpRetryBackoff = 100 * time.Millisecond
+)
+
+// ErrRemoteFileChanged is returned when the remote resource no longer matches the ETag seen when it was opened.
+var ErrRemoteFileChanged = errors.New("remote file changed since it was opened")
+
+// HTTPBinaryReader reads byte ranges of a remote file served by any web server supporting HTTP Range requests.
+// The URL is resolved once at opening (redirects are followed) and the ETag is pinned,
+// so that the file cannot change underneath the reader.
+// The requests are bound to the reader: closing it cancels the requests in flight.
+type HTTPBinaryReader struct {
+	client *http.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	url    string
+	etag   string
+	size   uint64
+}
+
+// NewHTTPBinaryReader creates a reader issuing its requests with client, whose Timeout should be set
+// so that a stalled server fails the reads.
+func NewHTTPBinaryReader(client *http.Client) BinaryReader {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HTTPBinaryReader{client: client, ctx: ctx, cancel: cancel}
+}
+
+func (f *HTTPBinaryReader) open(name string) error {
+	f.url = name
+	f.etag = ""
+
+	resp, err := f.get(0, 0)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	size, err := contentRangeSize(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", name, err)
+	}
+
+	// pin the final location and the version of the resource
+	f.url = resp.Request.URL.String()
+	f.etag = resp.Header.Get("ETag")
+	f.size = size
+	slog.Debug("opening remote file", "url", f.url, "etag", f.etag, "size", f.size)
+	return nil
+}
+
+func (f *HTTPBinaryReader) close() error {
+	slog.Info("closing remote file", "url", f.url)
+	f.cancel()
+	return nil
+}
+
+func (f *HTTPBinaryReader) read(offset uint64, p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if offset >= f.size {
+		return 0, io.EOF
+	}
+
+	// the range is inclusive and must not go past the end of the file
+	end := min(offset+uint64(len(p)), f.size) - 1
+
+	resp, err := f.get(offset, end)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	n, err := io.ReadFull(resp.Body, p[:end-offset+1])
+	if err != nil {
+		return n, fmt.Errorf("unable to read range [%d, %d] of %s: %w", offset, end, f.url, err)
+	}
+	if n < len(p) {
+		// the requested range goes past the end
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// get issues a ranged GET request, retrying on network errors and transient 5xx responses.
+func (f *HTTPBinaryReader) get(start, end uint64) (*http.Response, error) {
+	var lastErr error
+	for attempt := range httpMaxRetries + 1 {
+		if attempt > 0 {
+			select {
+			case <-time.After(httpRetryBackoff << (attempt - 1)):
+			case <-f.ctx.Done():
+				return nil, fmt.Errorf("range [%d, %d] cancelled: %w", start, end, f.ctx.Err())
+			}
+			slog.Debug("retrying range request", "url", f.url, "attempt", attempt, "error", lastErr)
+		}
+
+		req, err := http.NewRequestWithContext(f.ctx, http.MethodGet, f.url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		if f.etag != "" && !strings.HasPrefix(f.etag, "W/") {
+			// weak validators cannot be used with If-Match
+			req.Header.Set("If-Match", f.etag)
+		}
+
+		resp, err := f.client.Do(req)
+		if err != nil {
+			if f.ctx.Err() != nil {
+				return nil, fmt.Errorf("range [%d, %d] cancelled: %w", start, end, err)
+			}
+			lastErr = err
+			continue
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusPartialContent:
+			if f.etag != "" && resp.Header.Get("ETag") != f.etag {
+				resp.Body.Close()
+				return nil, ErrRemoteFileChanged
+			}
+			return resp, nil
+		case resp.StatusCode == http.StatusPreconditionFailed:
+			resp.Body.Close()
+			return nil, ErrRemoteFileChanged
+		case resp.StatusCode == http.StatusOK:
+			resp.Body.Close()
+			return nil, fmt.Errorf("server does not support range requests: %s", f.url)
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %w", resp.Status, fs.ErrNotExist)
+		case resp.StatusCode >= 500:
+			resp.Body.Close()
+			lastErr = fmt.Errorf("unexpected status: %s", resp.Status)
+			continue
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status for range [%d, %d]: %s", start, end, resp.Status)
+		}
+	}
+	return nil, fmt.Errorf("range [%d, %d] failed after %d attempts: %w", start, end, httpMaxRetries+1, lastErr)
+}
+
+// contentRangeSize extracts the complete length from a Content-Range header: "bytes 0-0/1234".
+func contentRangeSize(contentRange string) (uint64, error) {
+	_, size, found := strings.Cut(contentRange, "/")
+	if !found || size == "*" {
+		return 0, fmt.Errorf("unknown resource size in Content-Range: %q", contentRange)
+	}
+	return strconv.ParseUint(size, 10, 64)
+}
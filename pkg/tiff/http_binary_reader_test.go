@@ -0,0 +1,212 @@
+package tiff
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPBinaryReader(t *testing.T) {
+	data := []byte("II*\x00 0123456789abcdefghijklmnopqrstuvwxyz")
+	modTime := time.Date(2025, 2, 24, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		path        string
+		handler     func(opened *atomic.Bool, hits *atomic.Int32) http.HandlerFunc
+		wantOpenErr error
+		wantReadErr error
+		wantHits    int32 // requests counted by the handler, zero when not checked
+	}{
+		{
+			name: "range read",
+			path: "/slide.tiff",
+			handler: func(_ *atomic.Bool, _ *atomic.Int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("ETag", `"v1"`)
+					http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+				}
+			},
+		},
+		{
+			name: "redirect followed once and pinned",
+			path: "/moved",
+			handler: func(_ *atomic.Bool, hits *atomic.Int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/moved" {
+						hits.Add(1)
+						http.Redirect(w, r, "/slide.tiff", http.StatusFound)
+						return
+					}
+					w.Header().Set("ETag", `"v1"`)
+					http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+				}
+			},
+			wantHits: 1,
+		},
+		{
+			name: "transient 5xx retried",
+			path: "/slide.tiff",
+			handler: func(opened *atomic.Bool, hits *atomic.Int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if opened.Load() && hits.Add(1) <= 2 {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
+					w.Header().Set("ETag", `"v1"`)
+					http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+				}
+			},
+			wantHits: 3,
+		},
+		{
+			name: "persistent 5xx",
+			path: "/slide.tiff",
+			handler: func(opened *atomic.Bool, hits *atomic.Int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if opened.Load() {
+						hits.Add(1)
+						w.WriteHeader(http.StatusBadGateway)
+						return
+					}
+					http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+				}
+			},
+			wantReadErr: errAny,
+			wantHits:    httpMaxRetries + 1,
+		},
+		{
+			name: "ETag changed, If-Match honoured",
+			path: "/slide.tiff",
+			handler: func(opened *atomic.Bool, _ *atomic.Int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("ETag", `"v1"`)
+					if opened.Load() {
+						w.Header().Set("ETag", `"v2"`)
+					}
+					http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+				}
+			},
+			wantReadErr: ErrRemoteFileChanged,
+		},
+		{
+			name: "ETag changed, If-Match ignored",
+			path: "/slide.tiff",
+			handler: func(opened *atomic.Bool, _ *atomic.Int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					etag := `"v1"`
+					if opened.Load() {
+						etag = `"v2"`
+					}
+					r.Header.Del("If-Match")
+					w.Header().Set("ETag", etag)
+					http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+				}
+			},
+			wantReadErr: ErrRemoteFileChanged,
+		},
+		{
+			name: "range requests not supported",
+			path: "/slide.tiff",
+			handler: func(_ *atomic.Bool, _ *atomic.Int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write(data)
+				}
+			},
+			wantOpenErr: errAny,
+		},
+		{
+			name: "not found",
+			path: "/missing.tiff",
+			handler: func(_ *atomic.Bool, _ *atomic.Int32) http.HandlerFunc {
+				return http.NotFound
+			},
+			wantOpenErr: fs.ErrNotExist,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opened atomic.Bool
+			var hits atomic.Int32
+			server := httptest.NewServer(tt.handler(&opened, &hits))
+			defer server.Close()
+
+			reader := NewHTTPBinaryReader(server.Client())
+			err := reader.open(server.URL + tt.path)
+			switch {
+			case tt.wantOpenErr == nil && err != nil, tt.wantOpenErr == errAny && err == nil:
+				t.Fatalf("open error = %v, want %v", err, tt.wantOpenErr)
+			case tt.wantOpenErr != nil && tt.wantOpenErr != errAny && !errors.Is(err, tt.wantOpenErr):
+				t.Fatalf("open error = %v, want %v", err, tt.wantOpenErr)
+			}
+			if err != nil {
+				return
+			}
+			defer reader.close()
+			opened.Store(true)
+
+			p := make([]byte, 8)
+			n, err := reader.read(10, p)
+			switch {
+			case tt.wantReadErr == errAny && err == nil:
+				t.Fatal("read succeeded, want error")
+			case tt.wantReadErr != errAny && !errors.Is(err, tt.wantReadErr):
+				t.Fatalf("read error = %v, want %v", err, tt.wantReadErr)
+			case tt.wantReadErr == nil && !bytes.Equal(p[:n], data[10:18]):
+				t.Errorf("read = %q, want %q", p[:n], data[10:18])
+			}
+			if tt.wantHits > 0 && hits.Load() != tt.wantHits {
+				t.Errorf("handler hits = %d, want %d", hits.Load(), tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestHTTPBinaryReaderCloseCancelsStalledRead(t *testing.T) {
+	data := []byte("II*\x00 0123456789")
+	var opened atomic.Bool
+	stall := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if opened.Load() {
+			select {
+			case <-stall:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	defer close(stall)
+
+	reader := NewHTTPBinaryReader(server.Client())
+	if err := reader.open(server.URL); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	opened.Store(true)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := reader.read(0, make([]byte, 4))
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	reader.close()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("stalled read succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled read not cancelled by close")
+	}
+}
+
+// errAny expects any error.
+var errAny = errors.New("any error")
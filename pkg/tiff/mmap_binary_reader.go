@@ -0,0 +1,153 @@
+//go:build linux || darwin || freebsd
+
+package tiff
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// MmapBinaryReader maps the whole file read-only in memory.
+// Reads are plain memory copies and tile data is returned as slices of the mapping, without any system call
+// nor allocation. The mapping is reference counted: closing the reader while reads are in flight defers the unmapping
+// to the last of them, the slices returned remaining valid until the reader is closed.
+// Files which cannot be mapped, i.e. larger than the address space limit, are read with a FileBinaryReader.
+type MmapBinaryReader struct {
+	mapping  atomic.Pointer[mapping]
+	fallback BinaryReader
+}
+
+// mapping is the memory mapping of a file, unmapped once the reader and the reads in flight have all released it.
+type mapping struct {
+	data []byte
+	refs atomic.Int64
+}
+
+func NewMmapBinaryReader() BinaryReader {
+	return &MmapBinaryReader{}
+}
+
+func (f *MmapBinaryReader) open(name string) error {
+	if err := f.close(); err != nil {
+		slog.Warn("error closing file", "error", err)
+	}
+	f.fallback = nil
+
+	file, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("unable to open file %w", err)
+	}
+	// the mapping remains valid once the file is closed
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat file %w", err)
+	}
+
+	size := info.Size()
+	if limit := addressSpaceLimit(); size == 0 || uint64(size) > limit {
+		slog.Info("file cannot be mapped, using positional reads", "file", name, "size", size)
+		f.fallback = NewFileBinaryReader()
+		return f.fallback.open(name)
+	}
+
+	data, err := unix.Mmap(int(file.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		slog.Warn("unable to map file, using positional reads", "file", name, "error", err)
+		f.fallback = NewFileBinaryReader()
+		return f.fallback.open(name)
+	}
+	m := &mapping{data: data}
+	m.refs.Store(1) // the reference of the reader, released by close
+	f.mapping.Store(m)
+	return nil
+}
+
+func (f *MmapBinaryReader) close() error {
+	if f.fallback != nil {
+		return f.fallback.close()
+	}
+	if m := f.mapping.Swap(nil); m != nil {
+		return m.release()
+	}
+	return nil
+}
+
+func (f *MmapBinaryReader) read(offset uint64, p []byte) (int, error) {
+	if f.fallback != nil {
+		return f.fallback.read(offset, p)
+	}
+	m, err := f.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer m.release()
+
+	if offset >= uint64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// slice returns the bytes of the mapping, valid until the reader is closed.
+func (f *MmapBinaryReader) slice(offset, size uint64) ([]byte, error) {
+	if f.fallback != nil {
+		return sliceFrom(f.fallback, offset, size)
+	}
+	m, err := f.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer m.release()
+
+	if offset > uint64(len(m.data)) || size > uint64(len(m.data))-offset {
+		return nil, fmt.Errorf("unexpected end when reading %d bytes at %d: %w", size, offset, io.EOF)
+	}
+	return m.data[offset : offset+size : offset+size], nil
+}
+
+// acquire references the mapping for a read, failing once the reader is closed.
+func (f *MmapBinaryReader) acquire() (*mapping, error) {
+	m := f.mapping.Load()
+	for m != nil {
+		refs := m.refs.Load()
+		if refs == 0 {
+			break
+		}
+		if m.refs.CompareAndSwap(refs, refs+1) {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to read: %w", os.ErrClosed)
+}
+
+// release drops a reference to the mapping, unmapping it with the last one.
+func (m *mapping) release() error {
+	if m.refs.Add(-1) != 0 {
+		return nil
+	}
+	slog.Info("unmapping file", "size", len(m.data))
+	return unix.Munmap(m.data)
+}
+
+// addressSpaceLimit returns the largest size that may be mapped by the process.
+func addressSpaceLimit() uint64 {
+	limit := uint64(math.MaxInt)
+	var rlimit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_AS, &rlimit); err == nil && rlimit.Cur != unix.RLIM_INFINITY {
+		// keep half of the address space for the heap and the other mappings
+		limit = min(limit, uint64(rlimit.Cur)/2)
+	}
+	return limit
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package tiff
+
+// NewMmapBinaryReader falls back to positional reads on the platforms where mapping is not supported.
+func NewMmapBinaryReader() BinaryReader {
+	return NewFileBinaryReader()
+}
@@ -0,0 +1,108 @@
+//go:build linux || darwin || freebsd
+
+package tiff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeMmapTestFile(t *testing.T) (string, []byte) {
+	t.Helper()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	name := filepath.Join(t.TempDir(), "slide.tiff")
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name, data
+}
+
+// TestMmapBinaryReaderSlice reads as the tiles are read: the bytes are slices of the mapping, not copies.
+func TestMmapBinaryReaderSlice(t *testing.T) {
+	name, data := writeMmapTestFile(t)
+	reader := NewCacheBinaryReader(NewMmapBinaryReader())
+	if err := reader.open(name); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer reader.close()
+
+	tests := []struct {
+		name    string
+		offset  uint64
+		size    uint64
+		wantErr error
+	}{
+		{"first bytes", 0, 16, nil},
+		{"middle", 4000, 300, nil},
+		{"whole file", 0, uint64(len(data)), nil},
+		{"last bytes", uint64(len(data) - 7), 7, nil},
+		{"past the end", uint64(len(data) - 7), 8, io.EOF},
+		{"after the end", uint64(len(data) + 1), 1, io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sliceFrom(reader, tt.offset, tt.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("sliceFrom(%d, %d) error = %v, want %v", tt.offset, tt.size, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(got, data[tt.offset:tt.offset+tt.size]) {
+				t.Fatalf("sliceFrom(%d, %d) returned wrong bytes", tt.offset, tt.size)
+			}
+			again, _ := sliceFrom(reader, tt.offset, tt.size)
+			if &got[0] != &again[0] {
+				t.Errorf("sliceFrom(%d, %d) copied the bytes, a slice of the mapping expected", tt.offset, tt.size)
+			}
+			if cap(got) != len(got) {
+				t.Errorf("sliceFrom(%d, %d) capacity %d, appending would write to the mapping", tt.offset, tt.size, cap(got))
+			}
+		})
+	}
+}
+
+// TestMmapBinaryReaderCloseDuringReads closes the reader while reads are in flight, as the eviction of a slide
+// from the cache does: the unmapping waits for them, the later reads fail.
+func TestMmapBinaryReaderCloseDuringReads(t *testing.T) {
+	name, data := writeMmapTestFile(t)
+	for range 20 {
+		reader := NewMmapBinaryReader()
+		if err := reader.open(name); err != nil {
+			t.Fatalf("open: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		for g := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p := make([]byte, 4096)
+				for i := range 200 {
+					offset := uint64((g*200 + i) % (len(data) - len(p)))
+					_, err := reader.read(offset, p)
+					if errors.Is(err, os.ErrClosed) {
+						return
+					}
+					if err != nil || !bytes.Equal(p, data[offset:offset+uint64(len(p))]) {
+						t.Errorf("read(%d): %v", offset, err)
+						return
+					}
+				}
+			}()
+		}
+		if err := reader.close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		wg.Wait()
+
+		if _, err := reader.read(0, make([]byte, 4)); !errors.Is(err, os.ErrClosed) {
+			t.Fatalf("read after close: %v, want %v", err, os.ErrClosed)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package model
+
+import (
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"maps"
+	"strings"
+)
+
+type TIFFDirectory struct {
+	tags    map[tags.TagID]TIFFTag
+	subIFDs TIFFMetadata
+	private map[tags.TagID]TIFFDirectory
+}
+
+func NewTIFFDirectory(tags map[tags.TagID]TIFFTag) TIFFDirectory {
+	return TIFFDirectory{tags: tags}
+}
+
+// WithSubIFDs returns a copy of the directory holding the child directories referenced by its SubIFDs tag.
+func (d TIFFDirectory) WithSubIFDs(subIFDs TIFFMetadata) TIFFDirectory {
+	d.subIFDs = subIFDs
+	return d
+}
+
+// SubIFDs returns the child directories referenced by the SubIFDs tag (tag 330),
+// i.e. the reduced resolutions of OME-TIFF or libvips pyramids.
+func (d TIFFDirectory) SubIFDs() TIFFMetadata {
+	return d.subIFDs
+}
+
+// WithPrivateIFD returns a copy of the directory holding the directory referenced by the pointer tag tagID.
+func (d TIFFDirectory) WithPrivateIFD(tagID tags.TagID, ifd TIFFDirectory) TIFFDirectory {
+	private := make(map[tags.TagID]TIFFDirectory, len(d.private)+1)
+	maps.Copy(private, d.private)
+	private[tagID] = ifd
+	d.private = private
+	return d
+}
+
+// PrivateIFD returns the directory referenced by the pointer tag tagID,
+// i.e. ExifIFD, GPSInfoIFD or InteroperabilityIFD.
+func (d TIFFDirectory) PrivateIFD(tagID tags.TagID) (TIFFDirectory, error) {
+	if v, ok := d.private[tagID]; ok {
+		return v, nil
+	}
+	return TIFFDirectory{}, NewTagNotFoundError(tagID)
+}
+
+// ExifIFD returns the EXIF directory, holding the camera settings.
+func (d TIFFDirectory) ExifIFD() (TIFFDirectory, error) {
+	return d.PrivateIFD(tags.ExifIFD)
+}
+
+// GPSInfoIFD returns the GPS directory, its tags are identified by the GPS* constants.
+func (d TIFFDirectory) GPSInfoIFD() (TIFFDirectory, error) {
+	return d.PrivateIFD(tags.GPSInfoIFD)
+}
+
+// InteroperabilityIFD returns the Interoperability directory, usually referenced from the EXIF directory.
+func (d TIFFDirectory) InteroperabilityIFD() (TIFFDirectory, error) {
+	return d.PrivateIFD(tags.InteroperabilityIFD)
+}
+
+func (d TIFFDirectory) GetPyramidID() string {
+	getOrZero := func(t tags.TagID) int {
+		r, _ := d.GetIntTag(t)
+		return r
+	}
+	width := getOrZero(tags.TileWidth)
+	height := getOrZero(tags.TileLength)
+	rowsPerStrip := getOrZero(tags.RowsPerStrip)
+	return fmt.Sprintf("TileWidth:%d, TileLength:%d, RowsPerStrip:%d",
+		width, height, rowsPerStrip)
+}
+
+func (d TIFFDirectory) GetImageWidth() (int, error) {
+	return d.GetIntTag(tags.ImageWidth)
+}
+
+func (d TIFFDirectory) GetImageHeight() (int, error) {
+	return d.GetIntTag(tags.ImageLength)
+}
+
+func (d TIFFDirectory) GetTileWidth() (int, error) {
+	return d.GetIntTag(tags.TileWidth)
+}
+
+func (d TIFFDirectory) GetTileHeight() (int, error) {
+	return d.GetIntTag(tags.TileLength)
+}
+
+func (d TIFFDirectory) GetTileCount() (int, error) {
+	tag, err := d.Tag(tags.TileOffsets)
+	if err != nil {
+		return 0, err
+	}
+	return tag.ValuesCount(), nil
+}
+
+func (d TIFFDirectory) GetStripCount() (int, error) {
+	tag, err := d.Tag(tags.StripOffsets)
+	if err != nil {
+		return 0, err
+	}
+	return tag.ValuesCount(), nil
+}
+
+func (d TIFFDirectory) GetRowsPerStrip() (int, error) {
+	return d.GetIntTag(tags.RowsPerStrip)
+}
+
+func (d TIFFDirectory) GetCompression() (tags.CompressionType, error) {
+	compression, err := d.GetIntTag(tags.Compression)
+	if err != nil {
+		return 0, err
+	}
+	return tags.CompressionType(compression), nil
+}
+
+func (d TIFFDirectory) GetPhotometricInterpretation() (tags.PhotometricInterpretationType, error) {
+	compression, err := d.GetIntTag(tags.PhotometricInterpretation)
+	if err != nil {
+		return 0, err
+	}
+	return tags.PhotometricInterpretationType(compression), nil
+}
+
+// GetResolutionUnit returns the unit of XResolution and YResolution, inch by default.
+func (d TIFFDirectory) GetResolutionUnit() tags.ResolutionUnitType {
+	unit, err := d.GetIntTag(tags.ResolutionUnit)
+	if err != nil {
+		return tags.ResolutionUnitTypeInch
+	}
+	return tags.ResolutionUnitType(unit)
+}
+
+func (d TIFFDirectory) GetPredictor() (tags.PredictorType, error) {
+	predictor, err := d.GetIntTag(tags.Predictor)
+	if err != nil {
+		return 0, err
+	}
+	return tags.PredictorType(predictor), nil
+}
+
+func (d TIFFDirectory) GetJPEGTables() ([]byte, error) {
+	jpegTables, err := d.LoadedTag(tags.JPEGTables)
+	if err != nil {
+		return nil, err
+	}
+	return jpegTables.AsBytes(), nil
+}
+
+func (d TIFFDirectory) GetIccProfile() ([]byte, error) {
+	iccProfile, err := d.LoadedTag(tags.ICCProfile)
+	if err != nil {
+		return nil, err
+	}
+	return iccProfile.AsBytes(), nil
+}
+
+func (d TIFFDirectory) GetIntTag(tagID tags.TagID) (int, error) {
+	tag, err := d.Tag(tagID)
+	if err != nil {
+		return 0, err
+	}
+	v, err := tag.UintVal(0)
+	return int(v), err
+}
+
+// GetStringTag returns the value of an ASCII tag, without its NUL terminator.
+func (d TIFFDirectory) GetStringTag(tagID tags.TagID) (string, error) {
+	tag, err := d.LoadedTag(tagID)
+	if err != nil {
+		return "", err
+	}
+	values := tag.AsStrings()
+	if len(values) == 0 {
+		return "", fmt.Errorf("tag %s is not an ASCII tag", tags.IDsLabels[tagID])
+	}
+	return strings.TrimRight(values[0], "\x00 "), nil
+}
+
+// GetFloatTag returns the first value of a numeric tag, rationals being divided.
+func (d TIFFDirectory) GetFloatTag(tagID tags.TagID) (float64, error) {
+	tag, err := d.LoadedTag(tagID)
+	if err != nil {
+		return 0, err
+	}
+	if tag.ValuesCount() == 0 {
+		return 0, fmt.Errorf("tag %s has no value", tags.IDsLabels[tagID])
+	}
+	switch {
+	case tag.AsRationals() != nil:
+		return tag.AsRationals()[0].Float64(), nil
+	case tag.AsSignedRationals() != nil:
+		return tag.AsSignedRationals()[0].Float64(), nil
+	case tag.AsFloat32s() != nil:
+		return float64(tag.AsFloat32s()[0]), nil
+	case tag.AsFloat64s() != nil:
+		return tag.AsFloat64s()[0], nil
+	case tag.AsInt16s() != nil:
+		return float64(tag.AsInt16s()[0]), nil
+	case tag.AsInt32s() != nil:
+		return float64(tag.AsInt32s()[0]), nil
+	}
+	v, err := tag.UintVal(0)
+	return float64(v), err
+}
+
+func (d TIFFDirectory) Tag(tagID tags.TagID) (TIFFTag, error) {
+	if v, ok := d.tags[tagID]; ok {
+		return v, nil
+	}
+	return nil, NewTagNotFoundError(tagID)
+}
+
+// LoadedTag returns the tag with all its values, the values of a LazyTag being read,
+// so that the As* accessors of the result cannot fail.
+func (d TIFFDirectory) LoadedTag(tagID tags.TagID) (TIFFTag, error) {
+	tag, err := d.Tag(tagID)
+	if err != nil {
+		return nil, err
+	}
+	if lazy, ok := tag.(*LazyTag); ok {
+		return lazy.Load()
+	}
+	return tag, nil
+}
+
+func (d TIFFDirectory) Tags(tagIDs ...tags.TagID) ([]TIFFTag, error) {
+	r := make([]TIFFTag, len(tagIDs))
+	for i, tID := range tagIDs {
+		t, err := d.Tag(tID)
+		if err != nil {
+			return r, err
+		}
+		r[i] = t
+	}
+	return r, nil
+}
+
+func (d TIFFDirectory) String() string {
+	s := fmt.Sprintf("%v", d.tags)
+	for tagID, ifd := range d.private {
+		s += fmt.Sprintf(" %s:%v", tags.IDsLabels[tagID], ifd)
+	}
+	if len(d.subIFDs) > 0 {
+		s += fmt.Sprintf(" SubIFDs:%v", d.subIFDs)
+	}
+	return s
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"strings"
+)
+
+// Exif holds the camera settings stored in the EXIF directory of an image.
+// Missing tags are left to their zero value.
+type Exif struct {
+	ExposureTime          float64 `json:"exposureTime,omitempty"` // seconds
+	FNumber               float64 `json:"fNumber,omitempty"`
+	ExposureProgram       int     `json:"exposureProgram,omitempty"`
+	ISOSpeed              int     `json:"isoSpeed,omitempty"`
+	DateTimeOriginal      string  `json:"dateTimeOriginal,omitempty"` // YYYY:MM:DD HH:MM:SS
+	DateTimeDigitized     string  `json:"dateTimeDigitized,omitempty"`
+	ExposureBias          float64 `json:"exposureBias,omitempty"` // APEX
+	MeteringMode          int     `json:"meteringMode,omitempty"`
+	Flash                 int     `json:"flash,omitempty"`
+	FocalLength           float64 `json:"focalLength,omitempty"` // millimeters
+	FocalLengthIn35mmFilm int     `json:"focalLengthIn35mmFilm,omitempty"`
+	MakerNote             []byte  `json:"makerNote,omitempty"`
+	UserComment           string  `json:"userComment,omitempty"`
+	ColorSpace            int     `json:"colorSpace,omitempty"`
+	PixelXDimension       int     `json:"pixelXDimension,omitempty"`
+	PixelYDimension       int     `json:"pixelYDimension,omitempty"`
+	WhiteBalance          int     `json:"whiteBalance,omitempty"`
+	LensMake              string  `json:"lensMake,omitempty"`
+	LensModel             string  `json:"lensModel,omitempty"`
+	BodySerialNumber      string  `json:"bodySerialNumber,omitempty"`
+	ImageUniqueID         string  `json:"imageUniqueID,omitempty"`
+	InteroperabilityIndex string  `json:"interoperabilityIndex,omitempty"`
+	GPS                   *GPS    `json:"gps,omitempty"`
+}
+
+// GPS holds the location stored in the GPS directory of an image.
+type GPS struct {
+	Latitude  float64 `json:"latitude"`  // decimal degrees, negative in the southern hemisphere
+	Longitude float64 `json:"longitude"` // decimal degrees, negative west of Greenwich
+	Altitude  float64 `json:"altitude"`  // meters, negative below the sea level
+}
+
+// NewExif decodes the EXIF directory of d, along with its GPS and Interoperability directories.
+func NewExif(d TIFFDirectory) (Exif, error) {
+	exifIFD, err := d.ExifIFD()
+	if err != nil {
+		return Exif{}, err
+	}
+
+	floatOrZero := func(t tags.TagID) float64 {
+		r, _ := exifIFD.GetFloatTag(t)
+		return r
+	}
+	intOrZero := func(t tags.TagID) int {
+		r, _ := exifIFD.GetIntTag(t)
+		return r
+	}
+	stringOrEmpty := func(t tags.TagID) string {
+		r, _ := exifIFD.GetStringTag(t)
+		return r
+	}
+
+	exif := Exif{
+		ExposureTime:          floatOrZero(tags.ExposureTime),
+		FNumber:               floatOrZero(tags.FNumber),
+		ExposureProgram:       intOrZero(tags.ExposureProgram),
+		ISOSpeed:              intOrZero(tags.ISOSpeedRatings),
+		DateTimeOriginal:      stringOrEmpty(tags.DateTimeOriginal),
+		DateTimeDigitized:     stringOrEmpty(tags.DateTimeDigitized),
+		ExposureBias:          floatOrZero(tags.ExposureBiasValue),
+		MeteringMode:          intOrZero(tags.MeteringMode),
+		Flash:                 intOrZero(tags.Flash),
+		FocalLength:           floatOrZero(tags.FocalLength),
+		FocalLengthIn35mmFilm: intOrZero(tags.FocalLengthIn35mmFilm),
+		ColorSpace:            intOrZero(tags.ColorSpace),
+		PixelXDimension:       intOrZero(tags.PixelXDimension),
+		PixelYDimension:       intOrZero(tags.PixelYDimension),
+		WhiteBalance:          intOrZero(tags.WhiteBalance),
+		LensMake:              stringOrEmpty(tags.LensMake),
+		LensModel:             stringOrEmpty(tags.LensModel),
+		BodySerialNumber:      stringOrEmpty(tags.BodySerialNumber),
+		ImageUniqueID:         stringOrEmpty(tags.ImageUniqueID),
+	}
+	if makerNote, err := exifIFD.LoadedTag(tags.MakerNote); err == nil {
+		exif.MakerNote = makerNote.AsBytes()
+	}
+	if userComment, err := exifIFD.LoadedTag(tags.UserComment); err == nil {
+		exif.UserComment = decodeUserComment(userComment.AsBytes())
+	}
+	if interopIFD, err := exifIFD.InteroperabilityIFD(); err == nil {
+		exif.InteroperabilityIndex, _ = interopIFD.GetStringTag(tags.InteroperabilityIndex)
+	}
+
+	// the GPS directory is referenced from the image directory, some writers put it in the EXIF directory
+	gpsIFD, err := d.GPSInfoIFD()
+	if err != nil {
+		gpsIFD, err = exifIFD.GPSInfoIFD()
+	}
+	if err == nil {
+		exif.GPS = newGPS(gpsIFD)
+	}
+	return exif, nil
+}
+
+// decodeUserComment strips the 8 bytes character code prefixing the comment, only ASCII and undefined codes are supported.
+func decodeUserComment(data []byte) string {
+	if len(data) < 8 {
+		return ""
+	}
+	code := strings.TrimRight(string(data[:8]), "\x00 ")
+	if code != "ASCII" && code != "" {
+		return ""
+	}
+	return strings.TrimRight(string(data[8:]), "\x00 ")
+}
+
+func newGPS(d TIFFDirectory) *GPS {
+	gps := &GPS{
+		Latitude:  degrees(d, tags.GPSLatitude),
+		Longitude: degrees(d, tags.GPSLongitude),
+	}
+	if ref, _ := d.GetStringTag(tags.GPSLatitudeRef); ref == "S" {
+		gps.Latitude = -gps.Latitude
+	}
+	if ref, _ := d.GetStringTag(tags.GPSLongitudeRef); ref == "W" {
+		gps.Longitude = -gps.Longitude
+	}
+	gps.Altitude, _ = d.GetFloatTag(tags.GPSAltitude)
+	if ref, _ := d.GetIntTag(tags.GPSAltitudeRef); ref == 1 {
+		gps.Altitude = -gps.Altitude
+	}
+	return gps
+}
+
+// degrees converts a degrees, minutes and seconds tag to decimal degrees.
+func degrees(d TIFFDirectory, tagID tags.TagID) float64 {
+	tag, err := d.Tag(tagID)
+	if err != nil {
+		return 0
+	}
+	divisors := [...]float64{1, 60, 3600}
+	r := 0.0
+	for i, v := range tag.AsRationals() {
+		if i >= len(divisors) {
+			break
+		}
+		r += v.Float64() / divisors[i]
+	}
+	return r
+}
@@ -0,0 +1,206 @@
+package model
+
+import (
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// --------------------------
+// LazyTag implements Tag
+// --------------------------
+
+// ValuesLoader reads and decodes count values of a tag, starting at the value of index first.
+type ValuesLoader func(first, count uint64) (TIFFTag, error)
+
+// LazyTag is a tag whose values are left in the file until they are accessed.
+// Large arrays (TileOffsets, TileByteCounts...) are loaded page by page when UintVal is called,
+// other accesses load all the values at once.
+// Loaded values are kept, a LazyTag is safe for concurrent use: concurrent accesses to the same values
+// share a single read, performed without holding the lock, so that the other pages stay readable meanwhile.
+// The As* accessors return nil when the values cannot be loaded, Load and UintVal report the error.
+type LazyTag struct {
+	TagID    tags.TagID
+	Count    uint64
+	pageSize uint64 // 0 when the values can only be loaded all at once
+	loader   ValuesLoader
+
+	lock  sync.RWMutex
+	pages map[uint64]TIFFTag
+	all   TIFFTag
+	group singleflight.Group
+}
+
+func NewLazyTag(tagID tags.TagID, count, pageSize uint64, loader ValuesLoader) *LazyTag {
+	return &LazyTag{
+		TagID:    tagID,
+		Count:    count,
+		pageSize: pageSize,
+		loader:   loader,
+		pages:    make(map[uint64]TIFFTag),
+	}
+}
+
+func (t *LazyTag) GetTagID() tags.TagID {
+	return t.TagID
+}
+
+func (t *LazyTag) ValuesCount() int {
+	return int(t.Count)
+}
+
+func (t *LazyTag) AsBytes() []byte {
+	return t.loadedOrEmpty().AsBytes()
+}
+
+func (t *LazyTag) AsStrings() []string {
+	return t.loadedOrEmpty().AsStrings()
+}
+
+func (t *LazyTag) AsUint16s() []uint16 {
+	return t.loadedOrEmpty().AsUint16s()
+}
+
+func (t *LazyTag) AsUint32s() []uint32 {
+	return t.loadedOrEmpty().AsUint32s()
+}
+
+func (t *LazyTag) AsUint64s() []uint64 {
+	return t.loadedOrEmpty().AsUint64s()
+}
+
+func (t *LazyTag) AsRationals() []Rational {
+	return t.loadedOrEmpty().AsRationals()
+}
+
+func (t *LazyTag) AsSignedRationals() []SignedRational {
+	return t.loadedOrEmpty().AsSignedRationals()
+}
+
+func (t *LazyTag) AsInt8s() []int8 {
+	return t.loadedOrEmpty().AsInt8s()
+}
+
+func (t *LazyTag) AsInt16s() []int16 {
+	return t.loadedOrEmpty().AsInt16s()
+}
+
+func (t *LazyTag) AsInt32s() []int32 {
+	return t.loadedOrEmpty().AsInt32s()
+}
+
+func (t *LazyTag) AsFloat32s() []float32 {
+	return t.loadedOrEmpty().AsFloat32s()
+}
+
+func (t *LazyTag) AsFloat64s() []float64 {
+	return t.loadedOrEmpty().AsFloat64s()
+}
+
+// GetUintVal returns 0 when the value cannot be loaded, as for an index out of range, UintVal reports the error.
+func (t *LazyTag) GetUintVal(idx int) uint64 {
+	v, _ := t.UintVal(idx)
+	return v
+}
+
+func (t *LazyTag) UintVal(idx int) (uint64, error) {
+	if idx < 0 || uint64(idx) >= t.Count {
+		return 0, fmt.Errorf("index %d out of range for tag %s", idx, tags.IDsLabels[t.TagID])
+	}
+	t.lock.RLock()
+	all := t.all
+	t.lock.RUnlock()
+	if all == nil && t.pageSize == 0 {
+		var err error
+		if all, err = t.Load(); err != nil {
+			return 0, err
+		}
+	}
+	if all != nil {
+		return all.GetUintVal(idx), nil
+	}
+
+	page, err := t.loadPage(uint64(idx) / t.pageSize)
+	if err != nil {
+		return 0, err
+	}
+	return page.GetUintVal(idx % int(t.pageSize)), nil
+}
+
+func (t *LazyTag) String() string {
+	return fmt.Sprintf("%s: [%d]{lazy}", tags.IDsLabels[t.TagID], t.Count)
+}
+
+// Load returns the tag holding all the values, reading them on first call.
+// A failed read is not kept, the next call tries again.
+func (t *LazyTag) Load() (TIFFTag, error) {
+	t.lock.RLock()
+	all := t.all
+	t.lock.RUnlock()
+	if all != nil {
+		return all, nil
+	}
+
+	loaded, err, _ := t.group.Do("all", func() (any, error) {
+		t.lock.RLock()
+		all := t.all
+		t.lock.RUnlock()
+		if all != nil {
+			return all, nil
+		}
+		all, err := t.loader(0, t.Count)
+		if err != nil {
+			return nil, err
+		}
+		t.lock.Lock()
+		t.all = all
+		t.lock.Unlock()
+		return all, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loaded.(TIFFTag), nil
+}
+
+func (t *LazyTag) loadedOrEmpty() TIFFTag {
+	all, err := t.Load()
+	if err != nil {
+		return DataTag[byte]{TagID: t.TagID}
+	}
+	return all
+}
+
+func (t *LazyTag) loadPage(pageIdx uint64) (TIFFTag, error) {
+	t.lock.RLock()
+	page, ok := t.pages[pageIdx]
+	t.lock.RUnlock()
+	if ok {
+		return page, nil
+	}
+
+	loaded, err, _ := t.group.Do(strconv.FormatUint(pageIdx, 10), func() (any, error) {
+		t.lock.RLock()
+		page, ok := t.pages[pageIdx]
+		t.lock.RUnlock()
+		if ok {
+			return page, nil
+		}
+		first := pageIdx * t.pageSize
+		page, err := t.loader(first, min(t.pageSize, t.Count-first))
+		if err != nil {
+			return nil, err
+		}
+		t.lock.Lock()
+		t.pages[pageIdx] = page
+		t.lock.Unlock()
+		return page, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loaded.(TIFFTag), nil
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+)
+
+// tenfold loads the values 0, 10, 20... failing the first fail calls.
+func tenfold(calls *atomic.Int32, fail int32) ValuesLoader {
+	return func(first, count uint64) (TIFFTag, error) {
+		if calls.Add(1) <= fail {
+			return nil, errors.New("storage unavailable")
+		}
+		values := make([]uint32, count)
+		for i := range values {
+			values[i] = uint32(first+uint64(i)) * 10
+		}
+		return DataTag[uint32]{TagID: tags.TileOffsets, Values: values}, nil
+	}
+}
+
+func TestLazyTagUintVal(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     uint64
+		pageSize  uint64
+		fail      int32 // failing loads before the first success
+		wantCalls int32
+	}{
+		{name: "paged", count: 10, pageSize: 4, wantCalls: 3},
+		{name: "single page", count: 3, pageSize: 4, wantCalls: 1},
+		{name: "all at once", count: 10, pageSize: 0, wantCalls: 1},
+		{name: "failed page retried", count: 10, pageSize: 4, fail: 1, wantCalls: 4},
+		{name: "failed load retried", count: 10, pageSize: 0, fail: 2, wantCalls: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			tag := NewLazyTag(tags.TileOffsets, tt.count, tt.pageSize, tenfold(&calls, tt.fail))
+
+			for range tt.fail {
+				if _, err := tag.UintVal(0); err == nil {
+					t.Fatal("UintVal succeeded while the storage fails")
+				}
+			}
+			for i := range int(tt.count) {
+				v, err := tag.UintVal(i)
+				if err != nil {
+					t.Fatalf("UintVal(%d): %v", i, err)
+				}
+				if v != uint64(i)*10 {
+					t.Errorf("UintVal(%d) = %d, want %d", i, v, i*10)
+				}
+			}
+			if _, err := tag.UintVal(int(tt.count)); err == nil {
+				t.Errorf("UintVal(%d) succeeded, want out of range", tt.count)
+			}
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("loader called %d times, want %d", calls.Load(), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestLazyTagLoadError(t *testing.T) {
+	var calls atomic.Int32
+	tag := NewLazyTag(tags.JPEGTables, 4, 0, tenfold(&calls, 1))
+	directory := NewTIFFDirectory(map[tags.TagID]TIFFTag{tags.JPEGTables: tag})
+
+	if _, err := directory.LoadedTag(tags.JPEGTables); err == nil {
+		t.Fatal("LoadedTag succeeded while the storage fails")
+	}
+	loaded, err := directory.LoadedTag(tags.JPEGTables)
+	if err != nil {
+		t.Fatalf("LoadedTag after recovery: %v", err)
+	}
+	if got := loaded.AsUint32s(); len(got) != 4 || got[3] != 30 {
+		t.Errorf("AsUint32s() = %v, want [0 10 20 30]", got)
+	}
+}
+
+func TestLazyTagConcurrentLoads(t *testing.T) {
+	var calls atomic.Int32
+	loading := make(chan uint64, 16)
+	release := make(chan struct{})
+	load := tenfold(&calls, 0)
+	tag := NewLazyTag(tags.TileOffsets, 8, 4, func(first, count uint64) (TIFFTag, error) {
+		if first == 0 {
+			loading <- first
+			<-release
+		}
+		return load(first, count)
+	})
+	if _, err := tag.UintVal(5); err != nil { // second page loaded beforehand
+		t.Fatalf("UintVal(5): %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := tag.UintVal(1); err != nil || v != 10 {
+				t.Errorf("UintVal(1) = %d, %v, want 10", v, err)
+			}
+		}()
+	}
+	<-loading
+
+	// the first page being read, the second one is still served
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if v, err := tag.UintVal(6); err != nil || v != 60 {
+			t.Errorf("UintVal(6) = %d, %v, want 60", v, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("loaded page blocked by the read of another page")
+	}
+
+	close(release)
+	wg.Wait()
+	if calls.Load() != 2 {
+		t.Errorf("loader called %d times, want 2: one per page", calls.Load())
+	}
+	if len(loading) != 0 {
+		t.Errorf("first page read %d more times", len(loading))
+	}
+}
@@ -11,6 +11,14 @@ type Rational struct {
 	Denominator uint32
 }
 
+// Float64 returns the value of the fraction, 0 when the denominator is 0.
+func (r Rational) Float64() float64 {
+	if r.Denominator == 0 {
+		return 0
+	}
+	return float64(r.Numerator) / float64(r.Denominator)
+}
+
 func (r Rational) String() string {
 	return fmt.Sprintf("%d/%d", r.Numerator, r.Denominator)
 }
@@ -20,6 +28,14 @@ type SignedRational struct {
 	Denominator int32
 }
 
+// Float64 returns the value of the fraction, 0 when the denominator is 0.
+func (r SignedRational) Float64() float64 {
+	if r.Denominator == 0 {
+		return 0
+	}
+	return float64(r.Numerator) / float64(r.Denominator)
+}
+
 func (r SignedRational) String() string {
 	return fmt.Sprintf("%d/%d", r.Numerator, r.Denominator)
 }
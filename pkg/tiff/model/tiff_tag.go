@@ -1,8 +1,8 @@
 package model
 
 import (
-	"TiffReader/internal/tiffio/tags"
 	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
 )
 
 // --------------------------
@@ -25,6 +25,7 @@ type TIFFTag interface {
 	AsFloat32s() []float32
 	AsFloat64s() []float64
 	GetUintVal(int) uint64
+	UintVal(int) (uint64, error)
 }
 
 type TagType interface {
@@ -150,6 +151,13 @@ func (t DataTag[T]) GetUintVal(idx int) uint64 {
 	}
 }
 
+func (t DataTag[T]) UintVal(idx int) (uint64, error) {
+	if idx < 0 || idx >= len(t.Values) {
+		return 0, fmt.Errorf("index %d out of range for tag %s", idx, tags.IDsLabels[t.TagID])
+	}
+	return t.GetUintVal(idx), nil
+}
+
 func (t DataTag[T]) String() string {
 	if len(t.Values) > 9 {
 		return fmt.Sprintf("%s: [%d]{...}", tags.IDsLabels[t.TagID], len(t.Values))
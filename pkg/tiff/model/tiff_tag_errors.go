@@ -1,8 +1,8 @@
 package model
 
 import (
-	"TiffReader/internal/tiffio/tags"
 	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
 )
 
 type TagNotFoundError struct {
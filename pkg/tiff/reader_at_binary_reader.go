@@ -0,0 +1,46 @@
+package tiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ReaderAtBinaryReader adapts any io.ReaderAt (blob store client, tar archive entry, bytes.Reader...)
+// to a BinaryReader. The io.ReaderAt is owned by the caller: it is never closed by the reader.
+type ReaderAtBinaryReader struct {
+	section *io.SectionReader
+}
+
+// NewReaderAtBinaryReader creates a BinaryReader reading the first size bytes of r.
+// Reads past size are reported with io.EOF.
+func NewReaderAtBinaryReader(r io.ReaderAt, size int64) BinaryReader {
+	return &ReaderAtBinaryReader{section: io.NewSectionReader(r, 0, size)}
+}
+
+// NewTiffReaderFromReaderAt creates a TiffReader on top of an io.ReaderAt of the given size.
+// The returned reader is ready to use, it must not be opened.
+func NewTiffReaderFromReaderAt(r io.ReaderAt, size int64) *TiffReader {
+	return NewTiffReader(NewCacheBinaryReader(NewReaderAtBinaryReader(r, size)))
+}
+
+func (f *ReaderAtBinaryReader) open(string) error {
+	return nil
+}
+
+func (f *ReaderAtBinaryReader) close() error {
+	return nil
+}
+
+func (f *ReaderAtBinaryReader) read(offset uint64, p []byte) (int, error) {
+	if offset > math.MaxInt64 {
+		return 0, fmt.Errorf("value %d exceeds int64 maximum limit", offset)
+	}
+	n, err := f.section.ReadAt(p, int64(offset))
+	if errors.Is(err, io.EOF) && n == len(p) {
+		// the requested range ends exactly at the end of the data
+		return n, nil
+	}
+	return n, err
+}
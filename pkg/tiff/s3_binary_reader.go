@@ -0,0 +1,104 @@
+package tiff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// S3BinaryReader reads byte ranges of an object stored in an S3-compatible bucket.
+// Every read is served by a single ranged GET request, bounded by the timeout of the reader.
+// The requests are bound to the reader: closing it cancels the requests in flight.
+type S3BinaryReader struct {
+	client  *minio.Client
+	bucket  string
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+	key     string
+	size    uint64
+}
+
+// NewS3BinaryReader creates a reader of the objects of bucket, each request being bounded by timeout,
+// or only by the closing of the reader if zero.
+func NewS3BinaryReader(client *minio.Client, bucket string, timeout time.Duration) BinaryReader {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &S3BinaryReader{
+		client:  client,
+		bucket:  bucket,
+		timeout: timeout,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// requestContext returns the context of a request, cancelled by its timeout or by the closing of the reader.
+func (f *S3BinaryReader) requestContext() (context.Context, context.CancelFunc) {
+	if f.timeout <= 0 {
+		return context.WithCancel(f.ctx)
+	}
+	return context.WithTimeout(f.ctx, f.timeout)
+}
+
+func (f *S3BinaryReader) open(name string) error {
+	ctx, cancel := f.requestContext()
+	defer cancel()
+	info, err := f.client.StatObject(ctx, f.bucket, name, minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return fmt.Errorf("unable to stat object s3://%s/%s: %w: %w", f.bucket, name, fs.ErrNotExist, err)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to stat object s3://%s/%s: %w", f.bucket, name, err)
+	}
+	f.key = name
+	f.size = uint64(info.Size)
+	return nil
+}
+
+func (f *S3BinaryReader) close() error {
+	slog.Info("closing object", "bucket", f.bucket, "key", f.key)
+	f.cancel()
+	return nil
+}
+
+func (f *S3BinaryReader) read(offset uint64, p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if offset >= f.size {
+		return 0, io.EOF
+	}
+
+	// the range is inclusive and must not go past the end of the object
+	end := min(offset+uint64(len(p)), f.size) - 1
+
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(int64(offset), int64(end)); err != nil {
+		return 0, fmt.Errorf("invalid range [%d, %d]: %w", offset, end, err)
+	}
+
+	// the body is read under the same deadline as the request
+	ctx, cancel := f.requestContext()
+	defer cancel()
+	object, err := f.client.GetObject(ctx, f.bucket, f.key, opts)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get object s3://%s/%s: %w", f.bucket, f.key, err)
+	}
+	defer object.Close()
+
+	n, err := io.ReadFull(object, p[:end-offset+1])
+	if err != nil {
+		return n, fmt.Errorf("unable to read range [%d, %d] of s3://%s/%s: %w", offset, end, f.bucket, f.key, err)
+	}
+	if n < len(p) {
+		// the requested range goes past the end
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,147 @@
+package tiff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3StandIn serves the objects of the bucket "slides" as an S3-compatible server does: HEAD for StatObject,
+// ranged GET for GetObject, with path-style requests.
+func s3StandIn(t *testing.T, objects map[string][]byte) *minio.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		http.ServeContent(w, r, "", time.Date(2025, 2, 24, 10, 0, 0, 0, time.UTC), bytes.NewReader(data))
+	}))
+	t.Cleanup(server.Close)
+	return s3Client(t, server)
+}
+
+// s3Client creates a client of the S3-compatible server, with path-style requests.
+func s3Client(t *testing.T, server *httptest.Server) *minio.Client {
+	t.Helper()
+	endpoint, _ := url.Parse(server.URL)
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4("test", "test", ""),
+		Region:       "us-east-1",
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		t.Fatalf("unable to create S3 client: %v", err)
+	}
+	return client
+}
+
+func TestS3BinaryReaderRead(t *testing.T) {
+	data := []byte("II*\x00 0123456789abcdefghijklmnopqrstuvwxyz")
+	client := s3StandIn(t, map[string][]byte{"/slides/generic/slide.tiff": data})
+
+	reader := NewS3BinaryReader(client, "slides", time.Second)
+	if err := reader.open("generic/slide.tiff"); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer reader.close()
+
+	tests := []struct {
+		name    string
+		offset  uint64
+		size    int
+		want    []byte
+		wantErr error
+	}{
+		{"header", 0, 4, data[:4], nil},
+		{"middle", 10, 8, data[10:18], nil},
+		{"last byte", uint64(len(data) - 1), 1, data[len(data)-1:], nil},
+		{"past the end", uint64(len(data) - 3), 8, data[len(data)-3:], io.EOF},
+		{"after the end", uint64(len(data)), 4, nil, io.EOF},
+		{"empty", 5, 0, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := make([]byte, tt.size)
+			n, err := reader.read(tt.offset, p)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("read(%d, %d) error = %v, want %v", tt.offset, tt.size, err, tt.wantErr)
+			}
+			if !bytes.Equal(p[:n], tt.want) {
+				t.Errorf("read(%d, %d) = %q, want %q", tt.offset, tt.size, p[:n], tt.want)
+			}
+		})
+	}
+}
+
+func TestS3BinaryReaderOpenMissingObject(t *testing.T) {
+	client := s3StandIn(t, map[string][]byte{})
+	if err := NewS3BinaryReader(client, "slides", time.Second).open("missing.tiff"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("open of a missing object: %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestS3BinaryReaderStalledRead(t *testing.T) {
+	data := []byte("II*\x00 0123456789")
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		close   bool // close the reader during the read
+	}{
+		{"timeout", 100 * time.Millisecond, false},
+		{"close without timeout", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stall := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					select {
+					case <-stall:
+					case <-r.Context().Done():
+					}
+					return
+				}
+				w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+				http.ServeContent(w, r, "", time.Date(2025, 2, 24, 10, 0, 0, 0, time.UTC), bytes.NewReader(data))
+			}))
+			defer server.Close()
+			defer close(stall)
+
+			reader := NewS3BinaryReader(s3Client(t, server), "slides", tt.timeout)
+			if err := reader.open("slide.tiff"); err != nil {
+				t.Fatalf("open: %v", err)
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := reader.read(0, make([]byte, 4))
+				done <- err
+			}()
+			if tt.close {
+				time.Sleep(50 * time.Millisecond)
+				reader.close()
+			}
+
+			select {
+			case err := <-done:
+				if err == nil {
+					t.Fatal("stalled read succeeded")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("stalled read not cancelled")
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package tags
+
+// GPS Tag identifiers, only meaningful in the directory referenced by GPSInfoIFD
+const (
+	GPSVersionID    = TagID(uint16(0))  // Version of the GPS IFD
+	GPSLatitudeRef  = TagID(uint16(1))  // North (N) or South (S) latitude
+	GPSLatitude     = TagID(uint16(2))  // Latitude as degrees, minutes and seconds
+	GPSLongitudeRef = TagID(uint16(3))  // East (E) or West (W) longitude
+	GPSLongitude    = TagID(uint16(4))  // Longitude as degrees, minutes and seconds
+	GPSAltitudeRef  = TagID(uint16(5))  // Altitude above (0) or below (1) the sea level
+	GPSAltitude     = TagID(uint16(6))  // Altitude in meters
+	GPSTimeStamp    = TagID(uint16(7))  // UTC time as hours, minutes and seconds
+	GPSDateStamp    = TagID(uint16(29)) // UTC date as YYYY:MM:DD
+)
+
+// Interoperability Tag identifiers, only meaningful in the directory referenced by InteroperabilityIFD
+const (
+	InteroperabilityIndex   = TagID(uint16(1)) // Identification of the Interoperability rule, i.e. R98
+	InteroperabilityVersion = TagID(uint16(2)) // Version of the Interoperability rule
+)
@@ -52,6 +52,7 @@ const (
 	TileLength                = TagID(uint16(323))   // Height of a tile in pixels
 	TileOffsets               = TagID(uint16(324))   // Offset to the beginning of each tile
 	TileByteCounts            = TagID(uint16(325))   // Number of bytes in each tile
+	SubIFDs                   = TagID(uint16(330))   // Offsets to child IFDs, i.e. reduced resolutions
 	InkSet                    = TagID(uint16(332))   // Set of inks used
 	InkNames                  = TagID(uint16(333))   // Names of inks used
 	NumberOfInks              = TagID(uint16(334))   // Number of inks
@@ -83,11 +84,17 @@ const (
 	ImageID                   = TagID(uint16(32781)) // Identifier for the image
 	WangAnnotation            = TagID(uint16(32932)) // Annotation data in Wang format
 	Copyright                 = TagID(uint16(33432)) // Copyright notice for the image
+	ExposureTime              = TagID(uint16(33434)) // Exposure time in seconds
+	FNumber                   = TagID(uint16(33437)) // F number of the lens
 	ExifIFD                   = TagID(uint16(34665)) // Offset to Exif IFD (metadata for digital images)
 	ICCProfile                = TagID(uint16(34675)) // ICC profile for color management
+	ExposureProgram           = TagID(uint16(34850)) // Class of program used to set the exposure
 	GPSInfoIFD                = TagID(uint16(34853)) // Offset to GPS IFD for location information
+	ISOSpeedRatings           = TagID(uint16(34855)) // ISO speed of the camera
 	InterColorProfile         = TagID(uint16(34857)) // Embedded ICC profile
 	ExifVersion               = TagID(uint16(36864)) // Version of the Exif standard supported
+	DateTimeOriginal          = TagID(uint16(36867)) // Date and time the original image was generated
+	DateTimeDigitized         = TagID(uint16(36868)) // Date and time the image was stored as digital data
 	ShutterSpeedValue         = TagID(uint16(37377)) // Shutter speed in APEX units
 	ApertureValue             = TagID(uint16(37378)) // Lens aperture in APEX units
 	BrightnessValue           = TagID(uint16(37379)) // Brightness value in APEX units
@@ -127,6 +134,18 @@ const (
 	DeviceSettingDescription  = TagID(uint16(41995)) // Device setting description
 	SubjectDistanceRange      = TagID(uint16(41996)) // Distance to the subject
 	ImageUniqueID             = TagID(uint16(42016)) // Unique identifier for the image
+	BodySerialNumber          = TagID(uint16(42033)) // Serial number of the camera body
+	LensMake                  = TagID(uint16(42035)) // Manufacturer of the lens
+	LensModel                 = TagID(uint16(42036)) // Model of the lens
+	NDPIFormatFlag            = TagID(uint16(65420)) // Hamamatsu NDPI marker, offsets above 4 GiB are truncated to 32 bits
+	NDPISourceLens            = TagID(uint16(65421)) // Hamamatsu magnification of the image, -1 for the macro, -2 for the map
+	NDPIXOffsetFromCentre     = TagID(uint16(65422)) // Hamamatsu horizontal offset from the centre of the glass slide, in nm
+	NDPIYOffsetFromCentre     = TagID(uint16(65423)) // Hamamatsu vertical offset from the centre of the glass slide, in nm
+	NDPIFocalPlane            = TagID(uint16(65424)) // Hamamatsu Z offset of the image, in nm
+	NDPIMcuStarts             = TagID(uint16(65426)) // Hamamatsu offsets of the restart intervals in the JPEG strip
+	NDPIReference             = TagID(uint16(65427)) // Hamamatsu reference of the slide
+	NDPIMcuStartsHighBytes    = TagID(uint16(65432)) // Hamamatsu high 32 bits of the NDPIMcuStarts offsets
+	NDPIPropertyMap           = TagID(uint16(65449)) // Hamamatsu scanner properties, as key=value lines
 )
 
 // IDsLabels contains a map of TIFF tag IDs to their corresponding names
@@ -179,6 +198,7 @@ var IDsLabels = map[TagID]string{
 	323:   "TileLength",
 	324:   "TileOffsets",
 	325:   "TileByteCounts",
+	330:   "SubIFDs",
 	332:   "InkSet",
 	333:   "InkNames",
 	334:   "NumberOfInks",
@@ -210,11 +230,17 @@ var IDsLabels = map[TagID]string{
 	32781: "ImageID",
 	32932: "WangAnnotation",
 	33432: "Copyright",
+	33434: "ExposureTime",
+	33437: "FNumber",
 	34665: "ExifIFD",
 	34675: "ICCProfile",
+	34850: "ExposureProgram",
 	34853: "GPSInfoIFD",
+	34855: "ISOSpeedRatings",
 	34857: "InterColorProfile",
 	36864: "ExifVersion",
+	36867: "DateTimeOriginal",
+	36868: "DateTimeDigitized",
 	37377: "ShutterSpeedValue",
 	37378: "ApertureValue",
 	37379: "BrightnessValue",
@@ -254,20 +280,37 @@ var IDsLabels = map[TagID]string{
 	41995: "DeviceSettingDescription",
 	41996: "SubjectDistanceRange",
 	42016: "ImageUniqueID",
+	42033: "BodySerialNumber",
+	42035: "LensMake",
+	42036: "LensModel",
+	65420: "NDPIFormatFlag",
+	65421: "NDPISourceLens",
+	65422: "NDPIXOffsetFromCentre",
+	65423: "NDPIYOffsetFromCentre",
+	65424: "NDPIFocalPlane",
+	65426: "NDPIMcuStarts",
+	65427: "NDPIReference",
+	65432: "NDPIMcuStartsHighBytes",
+	65449: "NDPIPropertyMap",
 }
 
 type CompressionType int
 
 const (
-	CompressionTypeJPEG = CompressionType(7)
-	CompressionTypeLZW  = CompressionType(5)
+	CompressionTypeNone         = CompressionType(1)
+	CompressionTypeJPEG         = CompressionType(7)
+	CompressionTypeLZW          = CompressionType(5)
+	CompressionTypeDeflate      = CompressionType(8)
+	CompressionTypeAdobeDeflate = CompressionType(32946)
 )
 
 type PhotometricInterpretationType int
 
 const (
-	PhotometricInterpretationTypeRGB   = PhotometricInterpretationType(2)
-	PhotometricInterpretationTypeYCbCr = PhotometricInterpretationType(6)
+	PhotometricInterpretationTypeMinIsWhite = PhotometricInterpretationType(0)
+	PhotometricInterpretationTypeMinIsBlack = PhotometricInterpretationType(1)
+	PhotometricInterpretationTypeRGB        = PhotometricInterpretationType(2)
+	PhotometricInterpretationTypeYCbCr      = PhotometricInterpretationType(6)
 )
 
 type PredictorType int
@@ -275,3 +318,11 @@ type PredictorType int
 const (
 	PredictorTypeHorizontalDifferencing = PredictorType(2)
 )
+
+type ResolutionUnitType int
+
+const (
+	ResolutionUnitTypeNone       = ResolutionUnitType(1)
+	ResolutionUnitTypeInch       = ResolutionUnitType(2)
+	ResolutionUnitTypeCentimeter = ResolutionUnitType(3)
+)
@@ -0,0 +1,32 @@
+package tiff
+
+// rawTag is an IFD entry whose values are not decoded yet.
+// field holds the value/offset field of the entry: the values themselves when they fit, an offset otherwise.
+// dirOffset is the offset of the directory holding the entry, the 32-bit offsets of NDPI files are relative to it.
+type rawTag struct {
+	tagID     uint16
+	tagType   uint16
+	numValues uint64
+	field     []byte
+	dirOffset uint64
+}
+
+// size returns the number of bytes occupied by the values of the entry.
+func (t rawTag) size() uint64 {
+	return t.numValues * tagTypeSize(t.tagType)
+}
+
+// tagTypeSize returns the size in bytes of a single value of the TIFF field type, 0 if the type is unknown.
+func tagTypeSize(tagType uint16) uint64 {
+	switch tagType {
+	case 0x1, 0x2, 0x6, 0x7: // byte, ASCII, signed byte, undefined
+		return 1
+	case 0x3, 0x8: // short, signed short
+		return 2
+	case 0x4, 0x9, 0xb, 0xd: // long, signed long, float, IFD
+		return 4
+	case 0x5, 0xa, 0xc, 0x10, 0x11, 0x12: // rational, signed rational, double, LONG8, SIGNED LONG8, IFD8
+		return 8
+	}
+	return 0
+}
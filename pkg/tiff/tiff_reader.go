@@ -0,0 +1,779 @@
+package tiff
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+	"log"
+	"log/slog"
+	"math"
+	"slices"
+)
+
+const LogLevelTrace = -5
+
+// privateIFDTags are the tags pointing to a single directory of private metadata.
+// The Interoperability directory is usually referenced from the EXIF directory.
+var privateIFDTags = []tags.TagID{tags.ExifIFD, tags.GPSInfoIFD, tags.InteroperabilityIFD}
+
+const (
+	lazyTagThreshold    = 16 * 1024 // out-of-line values larger than this are only read on access
+	lazyTagPageSize     = 4096      // number of values loaded at once from a large numeric array
+	averageNumberOfTags = 20
+	maxSubIFDDepth      = 4 // SubIFDs of SubIFDs are allowed, up to this depth
+	TiffHeaderSize      = 16
+	TiffTagSize         = 12
+	TiffOffsetSize      = 4
+	BigTiffTagSize      = 20
+	BigTiffOffsetSize   = 8
+)
+
+// Errors of the tile reads, to be tested with errors.Is.
+var (
+	ErrLevelOutOfRange        = errors.New("level out of range")
+	ErrTileOutOfRange         = errors.New("tile out of range")
+	ErrUnsupportedCompression = errors.New("unsupported compression")
+)
+
+var LittleEndianSignature = []byte{0x49, 0x49}
+var BigEndianSignature = []byte{0x4d, 0x4d}
+var TiffMarker = []byte{0x2a, 0x00}
+var BigTiffMarker = []byte{0x2b, 0x00}
+
+// ndpiOffsetTags are the tags whose values are offsets in the file, truncated to 32 bits in NDPI files.
+var ndpiOffsetTags = []tags.TagID{tags.StripOffsets, tags.TileOffsets}
+
+// TiffReader is a structure that provides methods to read TIFF files.
+// It contains a BinaryReader for reading binary data.
+type TiffReader struct {
+	binary    CachedBinaryReader
+	isBigTiff bool
+	isNDPI    bool // classic TIFF with 64-bit offsets to the next IFD and truncated offsets, see fixNDPIOffset
+	byteOrder binary.ByteOrder
+}
+
+// NewTiffReader creates and returns a new instance of TiffReader,
+// initialized with the provided BinaryReader.
+func NewTiffReader(binary CachedBinaryReader) *TiffReader {
+	return &TiffReader{
+		binary: binary,
+	}
+}
+
+// Open opens the TIFF file specified by the name using the underlying BinaryReader.
+func (r *TiffReader) Open(name string) error {
+	return r.binary.open(name)
+}
+
+// Close closes the TIFF file and releases any resources used by the BinaryReader.
+// If an error occurs during closing, it logs a fatal error.
+func (r *TiffReader) Close() {
+	err := r.binary.close()
+	if err != nil {
+		log.Fatalf("unable to close reader")
+	}
+}
+
+// ReadMetadata reads the TIFF metadata from the image file.
+// It returns a TIFFMetadata structure containing the entries found.
+// In case of errors during reading, it returns an error with context.
+func (r *TiffReader) ReadMetadata() (model.TIFFMetadata, error) {
+	// small reads of IFDs and tag values are served from the cache until the metadata is complete
+	r.binary.openMetadata()
+	defer r.binary.closeMetadata()
+
+	nextOffset, err := r.readHeader()
+	if err != nil {
+		return model.TIFFMetadata{}, fmt.Errorf("unable to read header: %s", err)
+	}
+
+	directories, err := r.readIFDChain(nextOffset, make(map[uint64]bool), 0)
+	if err != nil {
+		return model.TIFFMetadata{}, err
+	}
+
+	slog.Debug("Metadata read", "directories", len(directories), "cache", r.binary.MetadataStats())
+	return directories, nil
+}
+
+// ByteOrder returns the byte order of the file, the one of the uncompressed samples larger than a byte.
+func (r *TiffReader) ByteOrder() binary.ByteOrder {
+	return r.byteOrder
+}
+
+// CacheStats returns the counters of the metadata cache while the metadata was read,
+// i.e. how many reads reached the underlying storage.
+func (r *TiffReader) CacheStats() CacheStats {
+	return r.binary.MetadataStats()
+}
+
+// GetTileData retrieves the tile data for a specific level and tile index from the TIFF image.
+// The data may be a slice of the mapping of the file (see MmapBinaryReader): it must not be modified,
+// and is valid until the reader is closed.
+func (r *TiffReader) GetTileData(level model.TIFFDirectory, tileIdx int) ([]byte, error) {
+	tileOffsetTag, err := level.Tag(tags.TileOffsets)
+	if err != nil {
+		return nil, err
+	}
+	tileBytesCountTag, err := level.Tag(tags.TileByteCounts)
+	if err != nil {
+		return nil, err
+	}
+
+	if tileIdx < 0 || tileIdx >= tileOffsetTag.ValuesCount() {
+		return nil, fmt.Errorf("%w: tileIdx %d of %d tiles", ErrTileOutOfRange, tileIdx, tileOffsetTag.ValuesCount())
+	}
+
+	tileOffset, err := tileOffsetTag.UintVal(tileIdx)
+	if err != nil {
+		return nil, err
+	}
+	tileBytesCount, err := tileBytesCountTag.UintVal(tileIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := r.sliceBytesAt(tileOffset, tileBytesCount)
+	if err != nil {
+		return nil, fmt.Errorf("GetTile: cannot read tile at level %d, tile %d: %w", level, tileIdx, err)
+	}
+
+	return data, nil
+}
+
+// GetStripData retrieves the strip data for a specific level and strip index from the TIFF image.
+func (r *TiffReader) GetStripData(level model.TIFFDirectory, stripIdx int) ([]byte, error) {
+	stripOffsetTag, err := level.Tag(tags.StripOffsets)
+	if err != nil {
+		return nil, err
+	}
+	stripBytesCountTag, err := level.Tag(tags.StripByteCounts)
+	if err != nil {
+		return nil, err
+	}
+
+	if stripIdx < 0 || stripIdx >= stripOffsetTag.ValuesCount() {
+		return nil, fmt.Errorf("%w: stripIdx %d of %d strips", ErrTileOutOfRange, stripIdx, stripOffsetTag.ValuesCount())
+	}
+
+	stripOffset, err := stripOffsetTag.UintVal(stripIdx)
+	if err != nil {
+		return nil, err
+	}
+	stripBytesCount, err := stripBytesCountTag.UintVal(stripIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := r.sliceBytesAt(stripOffset, stripBytesCount)
+	if err != nil {
+		return nil, fmt.Errorf("GetTile: cannot read strip at level %d, strip %d: %w", level, stripIdx, err)
+	}
+
+	return data, nil
+}
+
+// GetStripDataRange retrieves size bytes at offset in a strip, without reading the whole strip (i.e. NDPI strips).
+func (r *TiffReader) GetStripDataRange(level model.TIFFDirectory, stripIdx int, offset, size uint64) ([]byte, error) {
+	stripOffsetTag, err := level.Tag(tags.StripOffsets)
+	if err != nil {
+		return nil, err
+	}
+	stripBytesCountTag, err := level.Tag(tags.StripByteCounts)
+	if err != nil {
+		return nil, err
+	}
+
+	if stripIdx < 0 || stripIdx >= stripOffsetTag.ValuesCount() {
+		return nil, fmt.Errorf("%w: stripIdx %d of %d strips", ErrTileOutOfRange, stripIdx, stripOffsetTag.ValuesCount())
+	}
+
+	stripOffset, err := stripOffsetTag.UintVal(stripIdx)
+	if err != nil {
+		return nil, err
+	}
+	stripBytesCount, err := stripBytesCountTag.UintVal(stripIdx)
+	if err != nil {
+		return nil, err
+	}
+	if offset > stripBytesCount || size > stripBytesCount-offset {
+		return nil, fmt.Errorf("invalid range %d+%d in strip %d of %d bytes", offset, size, stripIdx, stripBytesCount)
+	}
+
+	data, err := r.sliceBytesAt(stripOffset+offset, size)
+	if err != nil {
+		return nil, fmt.Errorf("GetStripDataRange: cannot read strip %d at %d: %w", stripIdx, offset, err)
+	}
+
+	return data, nil
+}
+
+// --------------------------
+// decode TIFF binary blocks
+// --------------------------
+
+func (r *TiffReader) readHeader() (uint64, error) {
+	buffer, err := r.readBytesAt(0, TiffHeaderSize)
+	if err != nil {
+		return 0, fmt.Errorf("cannot seek to header: %w", err)
+	}
+
+	slog.Debug("readHeader", "hex", hex.EncodeToString(buffer[:TiffHeaderSize]))
+
+	if bytes.Equal(buffer[:2], BigEndianSignature) {
+		r.byteOrder = binary.BigEndian
+		slog.Debug("Byte-order is big-endian.")
+	} else if bytes.Equal(buffer[:2], LittleEndianSignature) {
+		r.byteOrder = binary.LittleEndian
+		slog.Debug("Byte-order is little-endian.")
+	} else {
+		return 0, errors.New(fmt.Sprintf("Unknown TIFF header: %s", hex.EncodeToString(buffer[:4])))
+	}
+
+	if bytes.Equal(buffer[2:4], TiffMarker) {
+		slog.Debug("Tiff format")
+		r.isBigTiff = false
+		nextIFD := uint64(r.byteOrder.Uint32(buffer[4:8]))
+		return nextIFD, nil
+	}
+
+	if bytes.Equal(buffer[2:4], BigTiffMarker) {
+		slog.Debug("BigTiff format")
+		r.isBigTiff = true
+		offsetSize := r.byteOrder.Uint32(buffer[4:8])
+		if offsetSize != 8 {
+			return 0, errors.New(fmt.Sprintf("BigTiff size of offsets not supported: %d", offsetSize))
+		}
+		nextIFD := r.byteOrder.Uint64(buffer[8:16])
+		return nextIFD, nil
+	}
+
+	return 0, errors.New(fmt.Sprintf("Not a TIFF header: %s", hex.EncodeToString(buffer[:8])))
+}
+
+// readIFDChain reads the directories linked from offset, with their SubIFDs.
+func (r *TiffReader) readIFDChain(nextOffset uint64, visited map[uint64]bool, depth int) (model.TIFFMetadata, error) {
+	directories := make([]model.TIFFDirectory, 0)
+	for nextOffset != 0 {
+		if visited[nextOffset] {
+			return nil, fmt.Errorf("IFD chain loops at offset %d", nextOffset)
+		}
+		visited[nextOffset] = true
+
+		var ifd model.TIFFDirectory
+		var err error
+		ifd, nextOffset, err = r.readIFD(nextOffset)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read IDF: %s", err)
+		}
+
+		subIFDOffsets, err := r.subIFDOffsets(ifd, depth)
+		if err != nil {
+			return nil, err
+		}
+		var subIFDs model.TIFFMetadata
+		for _, offset := range subIFDOffsets {
+			children, err := r.readIFDChain(offset, visited, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read SubIFD: %w", err)
+			}
+			subIFDs = append(subIFDs, children...)
+		}
+		if len(subIFDs) > 0 {
+			ifd = ifd.WithSubIFDs(subIFDs)
+		}
+		ifd = r.readPrivateIFDs(ifd, visited, depth)
+
+		directories = append(directories, ifd)
+		slog.Debug("Metadata", "IFD", ifd, "depth", depth)
+	}
+	return directories, nil
+}
+
+// subIFDOffsets returns the offsets stored in the SubIFDs tag of the directory, if any.
+func (r *TiffReader) subIFDOffsets(ifd model.TIFFDirectory, depth int) ([]uint64, error) {
+	tag, err := ifd.Tag(tags.SubIFDs)
+	if err != nil {
+		return nil, nil
+	}
+	if depth >= maxSubIFDDepth {
+		slog.Warn("SubIFDs nested too deeply, ignored", "depth", depth)
+		return nil, nil
+	}
+	offsets := make([]uint64, tag.ValuesCount())
+	for i := range offsets {
+		if offsets[i], err = tag.UintVal(i); err != nil {
+			return nil, fmt.Errorf("unable to read SubIFDs: %w", err)
+		}
+	}
+	return offsets, nil
+}
+
+// readPrivateIFDs attaches the EXIF, GPS and Interoperability directories referenced by ifd.
+// Those only hold descriptive metadata, a malformed one is logged and skipped.
+func (r *TiffReader) readPrivateIFDs(ifd model.TIFFDirectory, visited map[uint64]bool, depth int) model.TIFFDirectory {
+	for _, tagID := range privateIFDTags {
+		offset, ok := r.privateIFDOffset(ifd, tagID, visited, depth)
+		if !ok {
+			continue
+		}
+		visited[offset] = true
+
+		private, _, err := r.readIFD(offset)
+		if err != nil {
+			slog.Warn("Unable to read private IFD, ignored", "tag", tags.IDsLabels[tagID], "offset", offset, "error", err)
+			continue
+		}
+		ifd = ifd.WithPrivateIFD(tagID, r.readPrivateIFDs(private, visited, depth+1))
+	}
+	return ifd
+}
+
+// privateIFDOffset returns the offset stored in the pointer tag tagID, if it is worth following.
+func (r *TiffReader) privateIFDOffset(ifd model.TIFFDirectory, tagID tags.TagID, visited map[uint64]bool, depth int) (uint64, bool) {
+	tag, err := ifd.Tag(tagID)
+	if err != nil {
+		return 0, false
+	}
+	offset, err := tag.UintVal(0)
+	if err != nil || offset == 0 {
+		return 0, false
+	}
+	if depth >= maxSubIFDDepth || visited[offset] {
+		slog.Warn("Private IFD nested too deeply or already read, ignored", "tag", tags.IDsLabels[tagID], "offset", offset, "depth", depth)
+		return 0, false
+	}
+	return offset, true
+}
+
+func (r *TiffReader) readIFD(offset uint64) (model.TIFFDirectory, uint64, error) {
+	entries, nextOffset, err := r.readDirectoryEntries(offset)
+	if err != nil {
+		return model.TIFFDirectory{}, 0, err
+	}
+
+	tagMap := make(map[tags.TagID]model.TIFFTag, len(entries))
+	for _, entry := range entries {
+		tag, err := r.readTagValues(entry)
+		if err != nil {
+			return model.TIFFDirectory{}, 0, fmt.Errorf("readIFD: cannot read Tag: %w", err)
+		}
+		tagMap[tag.GetTagID()] = tag
+	}
+	return model.NewTIFFDirectory(tagMap), nextOffset, nil
+}
+
+// readDirectoryEntries reads the entries of the IFD at offset, without reading the values stored outside the entries.
+func (r *TiffReader) readDirectoryEntries(offset uint64) ([]rawTag, uint64, error) {
+	if r.isBigTiff {
+		return r.readBigIFDEntries(offset)
+	}
+	return r.readIFDEntries(offset)
+}
+
+func (r *TiffReader) readIFDEntries(offset uint64) ([]rawTag, uint64, error) {
+	dirOffset := offset
+	predictedSize := uint64(2 + averageNumberOfTags*TiffTagSize)
+	if err := r.binary.readBlock(offset, predictedSize); err != nil {
+		return nil, 0, fmt.Errorf("readIFD: cannot read block: %w", err)
+	}
+
+	buffer, err := r.readBytesAt(offset, 2)
+	if err != nil {
+		return nil, 0, fmt.Errorf("readIFD: cannot read: %w", err)
+	}
+
+	// read number of tags
+	nbTags := uint64(r.byteOrder.Uint16(buffer[:2]))
+
+	// complete an un-complete pre-read
+	if realSize := 2 + nbTags*TiffTagSize + TiffOffsetSize; realSize > predictedSize {
+		if err = r.binary.readBlock(offset+predictedSize, realSize-predictedSize); err != nil {
+			return nil, 0, fmt.Errorf("readIFD: cannot read block: %w", err)
+		}
+	}
+	offset += 2
+
+	// read all tags
+	entries := make([]rawTag, 0, nbTags)
+	for range nbTags {
+		entry, err := r.readTag(offset)
+		if err != nil {
+			return nil, 0, fmt.Errorf("readIFD: cannot read Tag: %w", err)
+		}
+		entry.dirOffset = dirOffset
+		if tags.TagID(entry.tagID) == tags.NDPIFormatFlag && !r.isNDPI {
+			slog.Debug("NDPI format")
+			r.isNDPI = true
+		}
+		entries = append(entries, entry)
+		offset += TiffTagSize
+	}
+
+	// offset to next IDF, on 64 bits in NDPI files
+	readOffsetAt := r.read4BytesOffsetAt
+	if r.isNDPI {
+		readOffsetAt = r.read8BytesOffsetAt
+	}
+	nextOffset, err := readOffsetAt(offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("readIFD: cannot read offset: %w", err)
+	}
+	return entries, nextOffset, nil
+}
+
+func (r *TiffReader) readBigIFDEntries(offset uint64) ([]rawTag, uint64, error) {
+	predictedSize := uint64(8 + averageNumberOfTags*BigTiffTagSize)
+	if err := r.binary.readBlock(offset, predictedSize); err != nil {
+		return nil, 0, fmt.Errorf("readBigIFD: cannot read block: %w", err)
+	}
+
+	buffer, err := r.readBytesAt(offset, 8)
+	if err != nil {
+		return nil, 0, fmt.Errorf("readBigIFD: cannot read: %w", err)
+	}
+
+	// read number of tags
+	nbTags := r.byteOrder.Uint64(buffer[:8])
+
+	// complete an un-complete pre-read
+	if realSize := 8 + nbTags*BigTiffTagSize + BigTiffOffsetSize; realSize > predictedSize {
+		if err = r.binary.readBlock(offset+predictedSize, realSize-predictedSize); err != nil {
+			return nil, 0, fmt.Errorf("readBigIFD: cannot read block: %w", err)
+		}
+	}
+	offset += 8
+
+	// read tags
+	entries := make([]rawTag, 0, nbTags)
+	for range nbTags {
+		entry, err := r.readBigTag(offset)
+		if err != nil {
+			return nil, 0, fmt.Errorf("readBigIFD: cannot read Tag: %w", err)
+		}
+		entries = append(entries, entry)
+		offset += BigTiffTagSize
+	}
+
+	// offset to next IDF
+	nextOffset, err := r.read8BytesOffsetAt(offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("readBigIFD: cannot read offset: %w", err)
+	}
+	return entries, nextOffset, nil
+}
+
+func (r *TiffReader) isValidSize(numValues, size uint64) bool {
+	if r.isBigTiff {
+		return numValues*size <= BigTiffOffsetSize
+	}
+	return numValues*size <= TiffOffsetSize
+}
+
+// isInline tells if the values of the entry are stored in the entry itself rather than at an offset.
+func (r *TiffReader) isInline(entry rawTag) bool {
+	return r.isValidSize(entry.numValues, tagTypeSize(entry.tagType))
+}
+
+func (r *TiffReader) offsetFrom(buffer []byte) uint64 {
+	if r.isBigTiff {
+		return r.byteOrder.Uint64(buffer[0:BigTiffOffsetSize])
+	}
+	return uint64(r.byteOrder.Uint32(buffer[0:TiffOffsetSize]))
+}
+
+// valueOffset returns the offset of the values of an entry stored outside of it.
+func (r *TiffReader) valueOffset(entry rawTag) uint64 {
+	offset := r.offsetFrom(entry.field)
+	if r.isNDPI {
+		return fixNDPIOffset(entry.dirOffset, offset)
+	}
+	return offset
+}
+
+// fixNDPIOffset restores the high bits of a 32-bit offset of a NDPI file.
+// NDPI files larger than 4 GiB are classic TIFF files whose offsets are truncated to 32 bits:
+// the data of a directory being written just before it, the offset is taken as the closest one below the directory.
+func fixNDPIOffset(dirOffset, offset uint64) uint64 {
+	fixed := dirOffset&^math.MaxUint32 | offset&math.MaxUint32
+	if fixed >= dirOffset && fixed > math.MaxUint32 {
+		fixed -= math.MaxUint32 + 1
+	}
+	return fixed
+}
+
+func (r *TiffReader) readTagValues(entry rawTag) (model.TIFFTag, error) {
+	if r.isInline(entry) {
+		return r.decodeTagValues(entry, entry.field[:entry.size()])
+	}
+	if r.isLazy(entry) {
+		return r.lazyTag(entry), nil
+	}
+	data, err := r.readBytesAt(r.valueOffset(entry), entry.size())
+	if err != nil {
+		return nil, fmt.Errorf("readTagValues: cannot read: %w", err)
+	}
+	return r.decodeTagValues(entry, data)
+}
+
+// isLazy tells if the values of the entry are too large to be read with the metadata.
+func (r *TiffReader) isLazy(entry rawTag) bool {
+	return !r.isInline(entry) && entry.size() > lazyTagThreshold && tagTypeSize(entry.tagType) > 0
+}
+
+// lazyTag creates a tag reading its values from the file on first access.
+// Numeric arrays are read page by page, strings and byte blobs all at once.
+func (r *TiffReader) lazyTag(entry rawTag) model.TIFFTag {
+	offset := r.valueOffset(entry)
+	elementSize := tagTypeSize(entry.tagType)
+
+	var pageSize uint64
+	if elementSize > 1 {
+		pageSize = lazyTagPageSize
+	}
+
+	return model.NewLazyTag(tags.TagID(entry.tagID), entry.numValues, pageSize, func(first, count uint64) (model.TIFFTag, error) {
+		data, err := r.readBytesAt(offset+first*elementSize, count*elementSize)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load values of tag %s: %w", tags.IDsLabels[tags.TagID(entry.tagID)], err)
+		}
+		page := entry
+		page.numValues = count
+		return r.decodeTagValues(page, data)
+	})
+}
+
+// decodeTagValues converts the values of an entry, data holds exactly entry.size() bytes.
+func (r *TiffReader) decodeTagValues(entry rawTag, data []byte) (model.TIFFTag, error) {
+	tagID := tags.TagID(entry.tagID)
+	numValues := entry.numValues
+
+	switch entry.tagType {
+	// byte, undefined
+	case 0x1, 0x7:
+		return model.DataTag[byte]{TagID: tagID, Values: data}, nil
+
+	// ASCII (nul terminated \0) string
+	case 0x2:
+		return model.DataTag[string]{TagID: tagID, Values: []string{string(data)}}, nil
+
+	// short
+	case 0x3:
+		values := readValuesFn(data, numValues, 2, r.bytesToUint16)
+		return model.DataTag[uint16]{TagID: tagID, Values: values}, nil
+
+	// long, IFD
+	case 0x4, 0xd:
+		values := readValuesFn(data, numValues, 4, r.bytesToUint32)
+		if r.isNDPI && slices.Contains(ndpiOffsetTags, tagID) {
+			return r.fixNDPIOffsets(entry, values), nil
+		}
+		return model.DataTag[uint32]{TagID: tagID, Values: values}, nil
+
+	// rational
+	case 0x5:
+		values := readValuesFn(data, numValues, 8, r.bytesToRational)
+		return model.DataTag[model.Rational]{TagID: tagID, Values: values}, nil
+
+	// signed byte
+	case 0x6:
+		values := readValuesFn(data, numValues, 1, r.bytesToInt8)
+		return model.DataTag[int8]{TagID: tagID, Values: values}, nil
+
+	// signed short
+	case 0x8:
+		values := readValuesFn(data, numValues, 2, r.bytesToInt16)
+		return model.DataTag[int16]{TagID: tagID, Values: values}, nil
+
+	// signed long
+	case 0x9:
+		values := readValuesFn(data, numValues, 4, r.bytesToInt32)
+		return model.DataTag[int32]{TagID: tagID, Values: values}, nil
+
+	// signed rational
+	case 0xa:
+		values := readValuesFn(data, numValues, 8, r.bytesToSignedRational)
+		return model.DataTag[model.SignedRational]{TagID: tagID, Values: values}, nil
+
+	// float
+	case 0xb:
+		values := readValuesFn(data, numValues, 4, r.bytesToFloat32)
+		return model.DataTag[float32]{TagID: tagID, Values: values}, nil
+
+	// double
+	case 0xc:
+		values := readValuesFn(data, numValues, 8, r.bytesToFloat64)
+		return model.DataTag[float64]{TagID: tagID, Values: values}, nil
+
+	// LONG8, IFD8
+	case 0x10, 0x12:
+		values := readValuesFn(data, numValues, 8, r.bytesToUint64)
+		return model.DataTag[uint64]{TagID: tagID, Values: values}, nil
+
+	// SIGNED LONG8
+	case 0x11:
+		values := readValuesFn(data, numValues, 8, r.bytesToInt64)
+		return model.DataTag[int64]{TagID: tagID, Values: values}, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("unknown tag type: %d", entry.tagType))
+}
+
+// fixNDPIOffsets widens the 32-bit offsets of the data of a NDPI directory, see fixNDPIOffset.
+func (r *TiffReader) fixNDPIOffsets(entry rawTag, values []uint32) model.TIFFTag {
+	offsets := make([]uint64, len(values))
+	for i, v := range values {
+		offsets[i] = fixNDPIOffset(entry.dirOffset, uint64(v))
+	}
+	return model.DataTag[uint64]{TagID: tags.TagID(entry.tagID), Values: offsets}
+}
+
+func (r *TiffReader) readTag(offset uint64) (rawTag, error) {
+	buffer, err := r.readBytesAt(offset, TiffTagSize)
+	if err != nil {
+		return rawTag{}, fmt.Errorf("readTag: cannot read: %w", err)
+	}
+
+	//slog.Debug("readTag", "hex", hex.EncodeToString(buffer[:TiffTagSize]))
+	slog.Log(context.Background(), LogLevelTrace, "readTag", "hex", hex.EncodeToString(buffer[:TiffTagSize]))
+
+	return rawTag{
+		tagID:     r.byteOrder.Uint16(buffer[:2]),
+		tagType:   r.byteOrder.Uint16(buffer[2:4]),
+		numValues: uint64(r.byteOrder.Uint32(buffer[4:8])),
+		field:     buffer[8:],
+	}, nil
+}
+
+func (r *TiffReader) readBigTag(offset uint64) (rawTag, error) {
+	buffer, err := r.readBytesAt(offset, BigTiffTagSize)
+	if err != nil {
+		return rawTag{}, fmt.Errorf("readBigTag: cannot read: %w", err)
+	}
+
+	slog.Log(context.Background(), LogLevelTrace, "readBigTag", "hex", hex.EncodeToString(buffer[:BigTiffTagSize]))
+
+	return rawTag{
+		tagID:     r.byteOrder.Uint16(buffer[:2]),
+		tagType:   r.byteOrder.Uint16(buffer[2:4]),
+		numValues: r.byteOrder.Uint64(buffer[4:12]),
+		field:     buffer[12:],
+	}, nil
+}
+
+// --------------------------
+// reading bytes
+// --------------------------
+
+func (r *TiffReader) read4BytesOffsetAt(offset uint64) (uint64, error) {
+	buffer, err := r.readBytesAt(offset, 4)
+	if err != nil {
+		return 0, err
+	}
+	nextOffset := uint64(r.byteOrder.Uint32(buffer))
+	return nextOffset, nil
+}
+
+func (r *TiffReader) read8BytesOffsetAt(offset uint64) (uint64, error) {
+	buffer, err := r.readBytesAt(offset, 8)
+	if err != nil {
+		return 0, err
+	}
+	nextOffset := r.byteOrder.Uint64(buffer)
+	return nextOffset, nil
+}
+
+// sliceBytesAt reads n bytes at offset, the returned slice may be shared with the underlying reader (i.e. mmap)
+// and must not be modified.
+func (r *TiffReader) sliceBytesAt(offset uint64, n uint64) ([]byte, error) {
+	data, err := sliceFrom(r.binary, offset, n)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %d bytes at %d: %w", n, offset, err)
+	}
+	return data, nil
+}
+
+func (r *TiffReader) readBytesAt(offset uint64, n uint64) ([]byte, error) {
+	buffer := make([]byte, n)
+	bytesRead, err := r.binary.read(offset, buffer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %d bytes at %d: %w", n, offset, err)
+	}
+	if bytesRead != int(n) {
+		return nil, fmt.Errorf("unexpected end when reading: expected %d, got %d", n, bytesRead)
+	}
+
+	return buffer, nil
+}
+
+// --------------------------
+// bytes conversion
+// --------------------------
+
+func (r *TiffReader) bytesToUint16(data []byte) uint16 {
+	return r.byteOrder.Uint16(data)
+}
+
+func (r *TiffReader) bytesToUint32(data []byte) uint32 {
+	return r.byteOrder.Uint32(data)
+}
+
+func (r *TiffReader) bytesToUint64(data []byte) uint64 {
+	return r.byteOrder.Uint64(data)
+}
+
+func (r *TiffReader) bytesToInt8(data []byte) int8 {
+	return int8(data[0])
+}
+
+func (r *TiffReader) bytesToInt16(data []byte) int16 {
+	return int16(r.byteOrder.Uint16(data[0:2]))
+}
+
+func (r *TiffReader) bytesToInt32(data []byte) int32 {
+	return int32(r.byteOrder.Uint32(data[0:4]))
+}
+
+func (r *TiffReader) bytesToInt64(data []byte) int64 {
+	return int64(r.byteOrder.Uint64(data[0:8]))
+}
+
+func (r *TiffReader) bytesToFloat32(data []byte) float32 {
+	return math.Float32frombits(r.byteOrder.Uint32(data[0:4]))
+}
+
+func (r *TiffReader) bytesToFloat64(data []byte) float64 {
+	return math.Float64frombits(r.byteOrder.Uint64(data[0:8]))
+}
+
+func (r *TiffReader) bytesToRational(data []byte) model.Rational {
+	return model.Rational{
+		Numerator:   r.byteOrder.Uint32(data[0:4]),
+		Denominator: r.byteOrder.Uint32(data[4:8]),
+	}
+}
+
+func (r *TiffReader) bytesToSignedRational(data []byte) model.SignedRational {
+	return model.SignedRational{
+		Numerator:   int32(r.byteOrder.Uint32(data[0:4])),
+		Denominator: int32(r.byteOrder.Uint32(data[4:8])),
+	}
+}
+
+// --------------------------
+// TIFF values logic
+// --------------------------
+
+func readValuesFn[T model.TagType](data []byte, numValues, size uint64, fromBytesFn func([]byte) T) []T {
+	values := make([]T, numValues)
+	for i := range numValues {
+		ofs := i * size
+		values[i] = fromBytesFn(data[ofs : ofs+size])
+	}
+	return values
+}
@@ -0,0 +1,40 @@
+package tiff
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/model"
+	"github.com/chennequin/fast-tiff-reader/pkg/tiff/tags"
+)
+
+func TestTiffReaderIndexOutOfRange(t *testing.T) {
+	tag := func(tagID tags.TagID) model.TIFFTag {
+		return model.DataTag[uint32]{TagID: tagID, Values: []uint32{16, 32}}
+	}
+	level := model.NewTIFFDirectory(map[tags.TagID]model.TIFFTag{
+		tags.TileOffsets:     tag(tags.TileOffsets),
+		tags.TileByteCounts:  tag(tags.TileByteCounts),
+		tags.StripOffsets:    tag(tags.StripOffsets),
+		tags.StripByteCounts: tag(tags.StripByteCounts),
+	})
+	reader := &TiffReader{}
+
+	tests := []struct {
+		name string
+		read func(idx int) ([]byte, error)
+	}{
+		{"GetTileData", func(idx int) ([]byte, error) { return reader.GetTileData(level, idx) }},
+		{"GetStripData", func(idx int) ([]byte, error) { return reader.GetStripData(level, idx) }},
+		{"GetStripDataRange", func(idx int) ([]byte, error) { return reader.GetStripDataRange(level, idx, 0, 1) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, idx := range []int{-1, 2} {
+				if _, err := tt.read(idx); !errors.Is(err, ErrTileOutOfRange) {
+					t.Errorf("%s(%d): %v, want %v", tt.name, idx, err, ErrTileOutOfRange)
+				}
+			}
+		})
+	}
+}